@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"golang.org/x/net/idna"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// Allowlist holds the domains, name servers, registrant organisations and
+// address ranges owned by the user
+type Allowlist struct {
+	Domains     []string
+	Nameservers []string
+	Orgs        []string
+	Networks    []*net.IPNet
+}
+
+var ownership *Allowlist
+
+// parses an allowlist made of "domain", "ns", "org" and "ip" entries, one per line
+func parseAllowlist(input io.Reader) (*Allowlist, error) {
+	allowlist := new(Allowlist)
+	scanner := bufio.NewScanner(input)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected entry type and value", line)
+		}
+		value := strings.Join(fields[1:], " ")
+		switch strings.ToLower(fields[0]) {
+		case "domain":
+			allowlist.Domains = append(allowlist.Domains, asciiDomain(value))
+		case "ns":
+			allowlist.Nameservers = append(allowlist.Nameservers, asciiDomain(value))
+		case "org":
+			allowlist.Orgs = append(allowlist.Orgs, value)
+		case "ip":
+			if !strings.Contains(value, "/") {
+				if strings.Contains(value, ":") {
+					value += "/128"
+				} else {
+					value += "/32"
+				}
+			}
+			_, network, err := net.ParseCIDR(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			allowlist.Networks = append(allowlist.Networks, network)
+		default:
+			return nil, fmt.Errorf("line %d: unknown entry type %q", line, fields[0])
+		}
+	}
+	return allowlist, scanner.Err()
+}
+
+// loads the allowlist file at path
+func loadAllowlist(path string) (*Allowlist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseAllowlist(file)
+}
+
+// returns the lowercased punycode form of a domain name, or the lowercased name when it is not a valid idn
+func asciiDomain(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if ascii, err := idna.Lookup.ToASCII(name); err == nil {
+		return ascii
+	}
+	return name
+}
+
+// reports whether host equals name or is one of its subdomains, name being in punycode form
+func matchesDomain(host, name string) bool {
+	host = asciiDomain(host)
+	return host == name || strings.HasSuffix(host, "."+name)
+}
+
+// reports whether the dns and whois data of a Record match the allowlist
+func (a *Allowlist) owns(r *Record) bool {
+	if a == nil {
+		return false
+	}
+	for _, d := range a.Domains {
+		if matchesDomain(r.Domain, d) {
+			return true
+		}
+	}
+	for _, ns := range a.Nameservers {
+		for _, host := range append(append([]string{}, r.NS...), r.WhoisNameservers...) {
+			if matchesDomain(host, ns) {
+				return true
+			}
+		}
+	}
+	for _, org := range a.Orgs {
+		if r.RegistrantOrg != "" && strings.EqualFold(strings.TrimSpace(r.RegistrantOrg), org) {
+			return true
+		}
+	}
+	for _, network := range a.Networks {
+		for _, ip := range append([]string{r.A}, r.IPs...) {
+			if parsed := net.ParseIP(ip); parsed != nil && network.Contains(parsed) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testAllowlist = `# defensive registrations
+domain examp1e.com
+ns ns1.brand-dns.net
+ns brand-dns.org
+org Example Brand Inc
+ip 192.0.2.0/24
+ip 198.51.100.7
+domain bücher.example
+ns xn--mnchen-3ya.example
+`
+
+func TestParseAllowlist(t *testing.T) {
+	allowlist, err := parseAllowlist(strings.NewReader(testAllowlist))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allowlist.Domains) != 2 || len(allowlist.Nameservers) != 3 || len(allowlist.Orgs) != 1 || len(allowlist.Networks) != 2 {
+		t.Errorf("unexpected allowlist %+v", allowlist)
+	}
+	if _, err := parseAllowlist(strings.NewReader("asn 64496")); err == nil {
+		t.Error("expected unknown entry type to fail")
+	}
+	if _, err := parseAllowlist(strings.NewReader("ip 192.0.2")); err == nil {
+		t.Error("expected invalid address to fail")
+	}
+}
+
+func TestAllowlistOwns(t *testing.T) {
+	allowlist, _ := parseAllowlist(strings.NewReader(testAllowlist))
+	for _, test := range []struct {
+		record Record
+		owned  bool
+	}{
+		{Record{Domain: "www.examp1e.com"}, true},
+		{Record{Domain: "exampl.com", NS: []string{"ns1.brand-dns.net"}}, true},
+		{Record{Domain: "exampl.com", WhoisNameservers: []string{"NS2.BRAND-DNS.ORG"}}, true},
+		{Record{Domain: "exampl.com", RegistrantOrg: "example brand inc"}, true},
+		{Record{Domain: "exampl.com", A: "192.0.2.77"}, true},
+		{Record{Domain: "exampl.com", IPs: []string{"203.0.113.1", "198.51.100.7"}}, true},
+		{Record{Domain: "exampl.com", A: "198.51.100.8", NS: []string{"ns1.brand-dns.network"}}, false},
+		{Record{Domain: "notexamp1e.com"}, false},
+		{Record{Domain: "xn--bcher-kva.example"}, true},
+		{Record{Domain: "shop.bücher.example"}, true},
+		{Record{Domain: "exampl.com", NS: []string{"ns1.münchen.example"}}, true},
+	} {
+		if owned := allowlist.owns(&test.record); owned != test.owned {
+			t.Errorf("%+v: expected owned %v, got %v", test.record, test.owned, owned)
+		}
+	}
+	var empty *Allowlist
+	if empty.owns(&Record{Domain: "examp1e.com"}) {
+		t.Error("expected nil allowlist not to own domains")
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"golang.org/x/net/idna"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// RPZ policy actions expressed as CNAME targets (draft-vixie-dnsop-dns-rpz)
+var rpzActions = map[string]string{
+	"nxdomain": ".",
+	"nodata":   "*.",
+	"drop":     "rpz-drop.",
+	"passthru": "rpz-passthru.",
+}
+
+// blocklistOutput writes one blocking rule per candidate meeting the blocklist filter
+type blocklistOutput struct {
+	w      io.Writer
+	seen   map[string]bool
+	header func(io.Writer) error
+	rule   func(io.Writer, string) error
+	wrote  bool
+}
+
+// checks the blocklist filter criteria and the RPZ policy action
+func validateBlocklistOptions() error {
+	for _, criterion := range strings.Split(*blockFilter, ",") {
+		switch strings.TrimSpace(criterion) {
+		case "registered", "resolved", "all":
+		default:
+			return fmt.Errorf("unknown blocklist filter %s, supported criteria are registered, resolved and all", criterion)
+		}
+	}
+	action := strings.ToLower(*rpzAction)
+	if _, ok := rpzActions[action]; !ok && net.ParseIP(action) == nil && !validateDomainName(strings.TrimSuffix(action, ".")) {
+		return fmt.Errorf("invalid rpz action %s, please supply nxdomain, nodata, drop, passthru, an address or a host name", *rpzAction)
+	}
+	if len(strings.Fields(*rpzSOA)) != 2 {
+		return fmt.Errorf("invalid rpz soa %q, please supply the primary name server and the hostmaster mailbox", *rpzSOA)
+	}
+	if net.ParseIP(*sinkhole) == nil {
+		return fmt.Errorf("invalid sinkhole %s, please supply an ip address", *sinkhole)
+	}
+	return nil
+}
+
+// reports whether a candidate meets the blocklist filter, owned and probably defensive domains are never blocked
+func blockable(r *Record) bool {
+	if r.Owned || r.Defensive || r.Similarity < *minScore {
+		return false
+	}
+	for _, criterion := range strings.Split(*blockFilter, ",") {
+		switch strings.TrimSpace(criterion) {
+		case "registered":
+			if r.Registration != registered {
+				return false
+			}
+		case "resolved":
+			if r.A == "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (o *blocklistOutput) write(r *Record) error {
+	if !blockable(r) {
+		return nil
+	}
+	domain, err := idna.Lookup.ToASCII(r.Domain)
+	if err != nil || o.seen[domain] {
+		return nil
+	}
+	o.seen[domain] = true
+	if !o.wrote {
+		if err := o.writeHeader(); err != nil {
+			return err
+		}
+	}
+	return o.rule(o.w, domain)
+}
+
+// writes the header of the blocklist once
+func (o *blocklistOutput) writeHeader() error {
+	o.wrote = true
+	if o.header == nil {
+		return nil
+	}
+	return o.header(o.w)
+}
+
+func (o *blocklistOutput) close() error {
+	// an empty zone still needs its SOA record
+	if !o.wrote {
+		return o.writeHeader()
+	}
+	return nil
+}
+
+// returns the RPZ record implementing the policy action
+func rpzRecord() string {
+	action := strings.ToLower(*rpzAction)
+	if target, ok := rpzActions[action]; ok {
+		return "CNAME " + target
+	}
+	if ip := net.ParseIP(action); ip != nil {
+		if ip.To4() != nil {
+			return "A " + ip.String()
+		}
+		return "AAAA " + ip.String()
+	}
+	return "CNAME " + strings.TrimSuffix(action, ".") + "."
+}
+
+func newRPZOutput(w io.Writer) outputWriter {
+	record := rpzRecord()
+	return &blocklistOutput{w: w, seen: make(map[string]bool),
+		header: func(w io.Writer) error {
+			soa := strings.Fields(*rpzSOA)
+			_, err := fmt.Fprintf(w, "$TTL 300\n@ IN SOA %s %s %d 3600 600 86400 300\n  IN NS %s\n",
+				soa[0], soa[1], time.Now().Unix(), soa[0])
+			return err
+		},
+		rule: func(w io.Writer, domain string) error {
+			_, err := fmt.Fprintf(w, "%s %s\n*.%s %s\n", domain, record, domain, record)
+			return err
+		}}
+}
+
+func newHostsOutput(w io.Writer) outputWriter {
+	return &blocklistOutput{w: w, seen: make(map[string]bool),
+		rule: func(w io.Writer, domain string) error {
+			_, err := fmt.Fprintf(w, "%s %s\n", *sinkhole, domain)
+			return err
+		}}
+}
+
+func newDnsmasqOutput(w io.Writer) outputWriter {
+	return &blocklistOutput{w: w, seen: make(map[string]bool),
+		rule: func(w io.Writer, domain string) error {
+			_, err := fmt.Fprintf(w, "address=/%s/%s\n", domain, *sinkhole)
+			return err
+		}}
+}
+
+func newUnboundOutput(w io.Writer) outputWriter {
+	return &blocklistOutput{w: w, seen: make(map[string]bool),
+		rule: func(w io.Writer, domain string) error {
+			_, err := fmt.Fprintf(w, "local-zone: \"%s.\" always_nxdomain\n", domain)
+			return err
+		}}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// candidates covering the blocklist filter criteria
+var blocklistRecords = []Record{
+	{Domain: "exampel.com", Registration: registered, A: "192.0.2.1", Similarity: 90},
+	{Domain: "examplea.com", Registration: registered, Similarity: 10},
+	{Domain: "exmple.com", Registration: unregistered},
+	{Domain: "examp1e.com", Registration: registered, A: "192.0.2.2", Owned: true},
+	{Domain: "exampel.com", Registration: registered, A: "192.0.2.1", Similarity: 90},
+	{Domain: "еxample.com", Registration: registered, A: "192.0.2.3", Similarity: 80},
+}
+
+// renders the blocklist records in format with the given filter and minimum score
+func renderBlocklist(t *testing.T, format, filter string, score int) string {
+	previousFilter, previousScore := *blockFilter, *minScore
+	*blockFilter, *minScore = filter, score
+	defer func() { *blockFilter, *minScore = previousFilter, previousScore }()
+	var buf bytes.Buffer
+	writer := outputFormats[format](&buf)
+	for i := range blocklistRecords {
+		if err := writer.write(&blocklistRecords[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestBlocklistFormats(t *testing.T) {
+	for _, test := range []struct {
+		format   string
+		filter   string
+		score    int
+		expected string
+	}{
+		{"hosts", "registered", 0, "0.0.0.0 exampel.com\n0.0.0.0 examplea.com\n0.0.0.0 xn--xample-2of.com\n"},
+		{"hosts", "registered,resolved", 0, "0.0.0.0 exampel.com\n0.0.0.0 xn--xample-2of.com\n"},
+		{"dnsmasq", "all", 85, "address=/exampel.com/0.0.0.0\n"},
+		{"unbound", "resolved", 0, "local-zone: \"exampel.com.\" always_nxdomain\nlocal-zone: \"xn--xample-2of.com.\" always_nxdomain\n"},
+	} {
+		if result := renderBlocklist(t, test.format, test.filter, test.score); result != test.expected {
+			t.Errorf("%s %s %d: expected\n%s\ngot\n%s", test.format, test.filter, test.score, test.expected, result)
+		}
+	}
+}
+
+func TestRPZZone(t *testing.T) {
+	previous := *rpzAction
+	defer func() { *rpzAction = previous }()
+	for action, record := range map[string]string{"nxdomain": "CNAME .", "drop": "CNAME rpz-drop.", "192.0.2.53": "A 192.0.2.53", "walled.example": "CNAME walled.example."} {
+		*rpzAction = action
+		zone := renderBlocklist(t, "rpz", "registered", 85)
+		lines := strings.Split(strings.TrimSpace(zone), "\n")
+		if len(lines) != 5 || !strings.HasPrefix(lines[1], "@ IN SOA localhost. hostmaster.localhost. ") {
+			t.Fatalf("%s: unexpected zone\n%s", action, zone)
+		}
+		if lines[3] != "exampel.com "+record || lines[4] != "*.exampel.com "+record {
+			t.Errorf("%s: unexpected rules\n%s", action, zone)
+		}
+	}
+	// an empty zone keeps its SOA record
+	if zone := renderBlocklist(t, "rpz", "registered", 100); !strings.Contains(zone, "IN SOA") {
+		t.Errorf("expected an SOA record in an empty zone, got\n%s", zone)
+	}
+}
+
+func TestValidateBlocklistOptions(t *testing.T) {
+	previous := []string{*blockFilter, *rpzAction, *rpzSOA, *sinkhole}
+	defer func() {
+		*blockFilter, *rpzAction, *rpzSOA, *sinkhole = previous[0], previous[1], previous[2], previous[3]
+	}()
+	for _, test := range []struct {
+		filter, action, soa, sinkhole string
+		valid                         bool
+	}{
+		{"registered", "nxdomain", "ns.example. hostmaster.example.", "0.0.0.0", true},
+		{"registered,resolved", "192.0.2.1", "ns.example. hostmaster.example.", "::", true},
+		{"parked", "nxdomain", "ns.example. hostmaster.example.", "0.0.0.0", false},
+		{"all", "not a name", "ns.example. hostmaster.example.", "0.0.0.0", false},
+		{"all", "drop", "ns.example.", "0.0.0.0", false},
+		{"all", "drop", "ns.example. hostmaster.example.", "0.0.0.300", false},
+		{"all", "drop", "ns.example. hostmaster.example.", "sinkhole.example", false},
+	} {
+		*blockFilter, *rpzAction, *rpzSOA, *sinkhole = test.filter, test.action, test.soa, test.sinkhole
+		if err := validateBlocklistOptions(); (err == nil) != test.valid {
+			t.Errorf("%s %s %s %s: expected valid %t, got %v", test.filter, test.action, test.soa, test.sinkhole, test.valid, err)
+		}
+	}
+}
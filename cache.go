@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// cacheDir holds the whois cache location, defaults to the user cache directory
+	cacheDir     string
+	cacheDirOnce sync.Once
+)
+
+// whoisCacheEntry is the on-disk representation of a cached whois result
+type whoisCacheEntry struct {
+	Fetched time.Time   `json:"fetched"`
+	Record  WhoisRecord `json:"record"`
+}
+
+// returns the cache file path of domain, empty if the domain cannot be cached
+func whoisCachePath(inputDomain string) string {
+	cacheDirOnce.Do(func() {
+		if dir, err := os.UserCacheDir(); err == nil && cacheDir == "" {
+			cacheDir = filepath.Join(dir, "dnsmorph", "whois")
+		}
+	})
+	if cacheDir == "" {
+		return ""
+	}
+	key := strings.ToLower(inputDomain)
+	if registrable, err := publicsuffix.EffectiveTLDPlusOne(key); err == nil {
+		key = registrable
+	}
+	key, err := idna.Lookup.ToASCII(key)
+	if err != nil || key == "" || strings.ContainsAny(key, `/\`) {
+		return ""
+	}
+	return filepath.Join(cacheDir, key+".json")
+}
+
+// reads a cached whois result, ignoring entries older than ttl
+func readWhoisCache(path string, ttl time.Duration) (WhoisRecord, bool) {
+	var entry whoisCacheEntry
+	data, err := ioutil.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &entry) != nil {
+		return WhoisRecord{}, false
+	}
+	if time.Since(entry.Fetched) > ttl {
+		return WhoisRecord{}, false
+	}
+	return entry.Record, true
+}
+
+// stores a whois result in the cache
+func writeWhoisCache(path string, record WhoisRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(whoisCacheEntry{Fetched: time.Now(), Record: record})
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first so concurrent readers never see partial entries
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "entry")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// performs a whois lookup through the disk cache, returns whether the result was a cache hit;
+// only registered domains are cached so that new registrations are never missed
+func cachedWhoisLookup(inputDomain string, useCache, refresh bool, ttl time.Duration) (WhoisRecord, bool, error) {
+	path := ""
+	if useCache {
+		path = whoisCachePath(inputDomain)
+	}
+	if path != "" && !refresh {
+		if record, ok := readWhoisCache(path, ttl); ok {
+			return record, true, nil
+		}
+	}
+	record, err := whoisLookup(inputDomain)
+	if err == nil && path != "" {
+		writeWhoisCache(path, record)
+	}
+	return record, false, err
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWhoisCache(t *testing.T) {
+	startRDAPStub(t)
+	previous := cacheDir
+	cacheDir = t.TempDir()
+	defer func() { cacheDir = previous }()
+
+	record, cached, err := cachedWhoisLookup("example.test", true, false, time.Hour)
+	if err != nil || cached {
+		t.Fatalf("expected uncached lookup, got cached=%v err=%v", cached, err)
+	}
+	record, cached, err = cachedWhoisLookup("www.example.test", true, false, time.Hour)
+	if err != nil || !cached {
+		t.Fatalf("expected cache hit, got cached=%v err=%v", cached, err)
+	}
+	if record.Registrar != "Example Registrar, LLC" {
+		t.Errorf("expected cached registrar, got '%s'", record.Registrar)
+	}
+	if _, cached, _ = cachedWhoisLookup("example.test", true, true, time.Hour); cached {
+		t.Error("expected refresh to bypass the cache")
+	}
+	if _, cached, _ = cachedWhoisLookup("example.test", true, false, 0); cached {
+		t.Error("expected expired entry to be ignored")
+	}
+	if _, cached, _ = cachedWhoisLookup("example.test", false, false, time.Hour); cached {
+		t.Error("expected disabled cache to be bypassed")
+	}
+	cachedWhoisLookup("free.test", true, false, time.Hour)
+	if _, err := os.Stat(whoisCachePath("free.test")); !os.IsNotExist(err) {
+		t.Errorf("expected no cache entry for an unregistered domain, got %v", err)
+	}
+	if _, cached, _ = cachedWhoisLookup("free.test", true, false, time.Hour); cached {
+		t.Error("expected unregistered domain to be looked up again")
+	}
+}
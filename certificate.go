@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"golang.org/x/net/idna"
+	"net"
+	"strings"
+	"time"
+)
+
+// issuers of free domain validated certificates, Cloudflare being left out as it only
+// issues certificates to sites behind its CDN, legitimate or not
+var freeCertIssuers = []string{
+	"let's encrypt",
+	"zerossl",
+	"buypass",
+	"cpanel",
+	"google trust services",
+	"ssl.com free",
+}
+
+// common names of free authority intermediates reserved to certificates managed by a cloud platform
+var managedCertIssuers = []string{
+	"gts ca 1d4",
+}
+
+// Certificate holds the certificate presented by a domain
+type Certificate struct {
+	Subject   string
+	SANs      []string
+	Issuer    string
+	NotBefore time.Time
+	NotAfter  time.Time
+	FreeDV    bool
+	Recent    bool
+}
+
+// connects to addr over tls with domain as server name and returns the leaf certificate
+func fetchCertificate(addr, domain string, timeout time.Duration) (*x509.Certificate, error) {
+	serverName, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	certificates := conn.ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		return nil, errors.New("no certificate presented")
+	}
+	return certificates[0], nil
+}
+
+// reports whether a certificate is domain validated and issued by a free certificate authority
+func isFreeDV(cert *x509.Certificate) bool {
+	if len(cert.Subject.Organization) > 0 {
+		return false
+	}
+	for _, managed := range managedCertIssuers {
+		if strings.EqualFold(cert.Issuer.CommonName, managed) {
+			return false
+		}
+	}
+	issuer := strings.ToLower(strings.Join(append(cert.Issuer.Organization, cert.Issuer.CommonName), " "))
+	for _, free := range freeCertIssuers {
+		if strings.Contains(issuer, free) {
+			return true
+		}
+	}
+	return false
+}
+
+// extracts the fields of a certificate, flagging certificates issued within days of now
+func inspectCertificate(cert *x509.Certificate, days int, now time.Time) Certificate {
+	issuer := cert.Issuer.CommonName
+	if len(cert.Issuer.Organization) > 0 {
+		issuer = cert.Issuer.Organization[0] + " " + issuer
+	}
+	return Certificate{
+		Subject:   cert.Subject.String(),
+		SANs:      cert.DNSNames,
+		Issuer:    strings.TrimSpace(issuer),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		FreeDV:    isFreeDV(cert),
+		Recent:    now.Sub(cert.NotBefore) <= time.Duration(days)*24*time.Hour,
+	}
+}
+
+// performs a certificate lookup on port 443 of input IP
+func certLookup(inputIP, domain string) (Certificate, error) {
+	cert, err := fetchCertificate(net.JoinHostPort(inputIP, "443"), domain, *tlsTimeout)
+	if err != nil {
+		return Certificate{}, err
+	}
+	return inspectCertificate(cert, *certDays, time.Now()), nil
+}
+
+// sets the certificate fields of a Record
+func (r *Record) setCertificate(cert Certificate) {
+	r.CertSubject = cert.Subject
+	r.CertSANs = cert.SANs
+	r.CertIssuer = cert.Issuer
+	r.CertNotBefore = cert.NotBefore.UTC().Format(time.RFC3339)
+	r.CertNotAfter = cert.NotAfter.UTC().Format(time.RFC3339)
+	r.CertFreeDV = cert.FreeDV
+	r.CertRecent = cert.Recent
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// issues a certificate for names signed by a throwaway authority named issuer
+func issueCertificate(t *testing.T, issuer string, subject pkix.Name, notBefore time.Time, names ...string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{issuer}, CommonName: "R3"},
+		NotBefore:             notBefore.Add(-time.Hour),
+		NotAfter:              notBefore.Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      subject,
+		DNSNames:     names,
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(90 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// starts a tls server presenting cert, returns its address
+func startTLSStub(t *testing.T, cert tls.Certificate) string {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Skip("cannot listen on tcp:", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestCertificateInspection(t *testing.T) {
+	now := time.Now()
+	for _, test := range []struct {
+		name      string
+		issuer    string
+		subject   pkix.Name
+		notBefore time.Time
+		freeDV    bool
+		recent    bool
+	}{
+		{"fresh free dv", "Let's Encrypt", pkix.Name{CommonName: "examp1e.test"}, now.Add(-48 * time.Hour), true, true},
+		{"old free dv", "Let's Encrypt", pkix.Name{CommonName: "examp1e.test"}, now.Add(-60 * 24 * time.Hour), true, false},
+		{"organisation validated", "Let's Encrypt", pkix.Name{CommonName: "examp1e.test", Organization: []string{"Example Inc"}}, now, false, true},
+		{"commercial authority", "DigiCert Inc", pkix.Name{CommonName: "examp1e.test"}, now, false, true},
+		{"cdn authority", "Cloudflare, Inc.", pkix.Name{CommonName: "examp1e.test"}, now, false, true},
+	} {
+		addr := startTLSStub(t, issueCertificate(t, test.issuer, test.subject, test.notBefore, "examp1e.test", "www.examp1e.test"))
+		leaf, err := fetchCertificate(addr, "examp1e.test", time.Second)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		cert := inspectCertificate(leaf, 30, now)
+		if cert.FreeDV != test.freeDV {
+			t.Errorf("%s: expected free dv %t, got %t", test.name, test.freeDV, cert.FreeDV)
+		}
+		if cert.Recent != test.recent {
+			t.Errorf("%s: expected recent %t, got %t", test.name, test.recent, cert.Recent)
+		}
+		if len(cert.SANs) != 2 || cert.SANs[1] != "www.examp1e.test" {
+			t.Errorf("%s: unexpected alternative names %v", test.name, cert.SANs)
+		}
+		if cert.Issuer != test.issuer+" R3" {
+			t.Errorf("%s: expected issuer '%s R3', got '%s'", test.name, test.issuer, cert.Issuer)
+		}
+	}
+}
+
+func TestIsFreeDV(t *testing.T) {
+	for _, test := range []struct {
+		issuer pkix.Name
+		freeDV bool
+	}{
+		{pkix.Name{Organization: []string{"Google Trust Services"}, CommonName: "WR1"}, true},
+		{pkix.Name{Organization: []string{"Google Trust Services LLC"}, CommonName: "GTS CA 1D4"}, false},
+		{pkix.Name{Organization: []string{"Cloudflare, Inc."}, CommonName: "Cloudflare Inc ECC CA-3"}, false},
+		{pkix.Name{Organization: []string{"Let's Encrypt"}, CommonName: "E5"}, true},
+	} {
+		if freeDV := isFreeDV(&x509.Certificate{Issuer: test.issuer}); freeDV != test.freeDV {
+			t.Errorf("%s: expected free dv %t, got %t", test.issuer, test.freeDV, freeDV)
+		}
+	}
+}
+
+func TestCertificateUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen on tcp:", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	if _, err := fetchCertificate(addr, "examp1e.test", time.Second); err == nil {
+		t.Error("expected an error from a closed port")
+	}
+}
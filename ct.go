@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bufio"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+	"golang.org/x/net/websocket"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	ctSet         = flag.NewFlagSet("ct", flag.ContinueOnError)
+	ctDomain      = ctSet.String("d", "", "protected domain")
+	ctList        = ctSet.String("l", "", "protected domains list filepath")
+	ctFile        = ctSet.String("file", "", "json lines file of recorded ct entries")
+	ctLog         = ctSet.String("log", "", "ct log url, e.g. https://ct.googleapis.com/logs/argon2021/")
+	ctCertstream  = ctSet.String("certstream", "", "certstream websocket url, e.g. wss://certstream.calidog.io/")
+	ctStart       = ctSet.Int64("start", -1, "first ct log entry index (default latest entries)")
+	ctCount       = ctSet.Int64("count", 0, "number of ct entries to read (default 256 for logs, unlimited otherwise)")
+	ctCertDays    = ctSet.Int("cert-days", 30, "days within which a certificate is reported as recent")
+	ctJSON        = ctSet.Bool("json", false, "output to json lines, one object per match as it is found")
+	ctVerbose     = ctSet.Bool("v", false, "enable verbosity")
+	ctDescription = "dnsmorph ct -d domain | -l domains_file -file entries.jsonl | -log url | -certstream url [-start n] [-count n] [-cert-days n] [-json] [-v]"
+	ctBatchSize   = int64(64)
+	skeletonMap   map[rune]rune
+	skeletonOnce  sync.Once
+)
+
+// ctEntry is a certificate observed in a certificate transparency feed
+type ctEntry struct {
+	Source string
+	Seen   time.Time
+	Cert   Certificate
+}
+
+// certstreamMessage is the subset of a certstream message used by dnsmorph
+type certstreamMessage struct {
+	MessageType string `json:"message_type"`
+	Data        struct {
+		Seen     float64 `json:"seen"`
+		LeafCert struct {
+			Subject    certstreamName `json:"subject"`
+			Issuer     certstreamName `json:"issuer"`
+			NotBefore  float64        `json:"not_before"`
+			NotAfter   float64        `json:"not_after"`
+			AllDomains []string       `json:"all_domains"`
+		} `json:"leaf_cert"`
+		Source struct {
+			URL string `json:"url"`
+		} `json:"source"`
+	} `json:"data"`
+}
+
+// certstreamName is a distinguished name as serialised by certstream
+type certstreamName struct {
+	CN *string `json:"CN"`
+	O  *string `json:"O"`
+}
+
+// ctLogEntry is an entry of an RFC 6962 get-entries response
+type ctLogEntry struct {
+	LeafInput string `json:"leaf_input"`
+	ExtraData string `json:"extra_data"`
+}
+
+// ctMatcher matches certificate names against the permutations and brands of protected domains
+type ctMatcher struct {
+	permutations map[string]Record
+	protected    map[string]bool
+	brands       []ctBrand
+}
+
+// ctBrand is a protected domain and the skeleton of its label
+type ctBrand struct {
+	target   string
+	skeleton string
+}
+
+// converts a distinguished name to a pkix name
+func (n certstreamName) pkix() pkix.Name {
+	var name pkix.Name
+	if n.CN != nil {
+		name.CommonName = *n.CN
+	}
+	if n.O != nil && *n.O != "" {
+		name.Organization = []string{*n.O}
+	}
+	return name
+}
+
+// converts seconds since the epoch to a time
+func unixSeconds(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+// returns the skeleton of a name, mapping homoglyphs to the latin character they imitate
+// and dropping hyphens, so that lookalike names share the skeleton of the brand
+func skeleton(name string) string {
+	skeletonOnce.Do(func() {
+		skeletonMap = map[rune]rune{'0': 'o', '1': 'l', 'i': 'l', '3': 'e', '5': 's'}
+		latin := make([]rune, 0, len(homoglyphs))
+		for char := range homoglyphs {
+			latin = append(latin, char)
+		}
+		sort.Slice(latin, func(i, j int) bool { return latin[i] < latin[j] })
+		for _, char := range latin {
+			for _, glyph := range homoglyphs[char] {
+				if _, ok := skeletonMap[glyph]; !ok && glyph > 127 {
+					skeletonMap[glyph] = char
+				}
+			}
+		}
+	})
+	if unicode, err := idna.ToUnicode(name); err == nil {
+		name = unicode
+	}
+	var b strings.Builder
+	for _, char := range strings.ToLower(name) {
+		if mapped, ok := skeletonMap[char]; ok {
+			char = mapped
+		}
+		if char != '-' {
+			b.WriteRune(char)
+		}
+	}
+	return strings.NewReplacer("rn", "m", "vv", "w").Replace(b.String())
+}
+
+// builds a matcher from the permutations of the protected domains
+func newCTMatcher(targets []string) *ctMatcher {
+	m := &ctMatcher{permutations: make(map[string]Record), protected: make(map[string]bool)}
+	for _, target := range targets {
+		sanitizedDomain, tld := processInput(target)
+		for _, t := range permutationTargets(sanitizedDomain) {
+			for _, result := range t.Function(t.TargetDomain) {
+				ascii, err := idna.Lookup.ToASCII(result + "." + tld)
+				if err != nil {
+					continue
+				}
+				if _, ok := m.permutations[ascii]; !ok {
+					m.permutations[ascii] = Record{Target: target, Technique: t.Technique}
+				}
+			}
+		}
+		if registrable, err := publicsuffix.EffectiveTLDPlusOne(target); err == nil {
+			m.protected[registrable] = true
+		}
+		m.brands = append(m.brands, ctBrand{target, skeleton(sanitizedDomain)})
+	}
+	return m
+}
+
+// matches a certificate name against the permutation set and the brand skeletons
+func (m *ctMatcher) match(name string) (Record, bool) {
+	ascii, err := idna.Lookup.ToASCII(strings.TrimPrefix(strings.ToLower(name), "*."))
+	if err != nil || ascii == "" {
+		return Record{}, false
+	}
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(ascii)
+	if err != nil || m.protected[registrable] {
+		return Record{}, false
+	}
+	for _, candidate := range []string{ascii, registrable} {
+		if r, ok := m.permutations[candidate]; ok {
+			r.Domain = ascii
+			return r, true
+		}
+	}
+	suffix, _ := publicsuffix.PublicSuffix(registrable)
+	host := skeleton(strings.TrimSuffix(ascii, "."+suffix))
+	label := skeleton(strings.TrimSuffix(registrable, "."+suffix))
+	for _, brand := range m.brands {
+		// short brands only match exactly to keep the noise down
+		if label == brand.skeleton || (len(brand.skeleton) >= 4 && strings.Contains(host, brand.skeleton)) {
+			return Record{Target: brand.target, Technique: "skeleton", Domain: ascii}, true
+		}
+	}
+	return Record{}, false
+}
+
+// decodes an RFC 6962 log entry, returning the leaf or pre-certificate
+func parseCTLogEntry(entry ctLogEntry) (*x509.Certificate, time.Time, error) {
+	leaf, err := base64.StdEncoding.DecodeString(entry.LeafInput)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	// MerkleTreeLeaf: version, leaf type, timestamp and entry type precede the entry
+	if len(leaf) < 12 || leaf[0] != 0 || leaf[1] != 0 {
+		return nil, time.Time{}, errors.New("unsupported merkle tree leaf")
+	}
+	seen := time.Unix(0, int64(binary.BigEndian.Uint64(leaf[2:10]))*int64(time.Millisecond))
+	var der []byte
+	switch binary.BigEndian.Uint16(leaf[10:12]) {
+	case 0:
+		der, err = readASN1Cert(leaf[12:])
+	case 1:
+		// the pre-certificate is the first certificate of the extra data chain
+		var extra []byte
+		if extra, err = base64.StdEncoding.DecodeString(entry.ExtraData); err == nil {
+			der, err = readASN1Cert(extra)
+		}
+	default:
+		err = errors.New("unsupported log entry type")
+	}
+	if err != nil {
+		return nil, seen, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, seen, err
+}
+
+// reads a certificate prefixed with its 24 bit length
+func readASN1Cert(b []byte) ([]byte, error) {
+	if len(b) < 3 {
+		return nil, errors.New("truncated certificate")
+	}
+	length := int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+	if len(b) < 3+length {
+		return nil, errors.New("truncated certificate")
+	}
+	return b[3 : 3+length], nil
+}
+
+// parses a certstream message or an RFC 6962 log entry, ignoring heartbeats
+func parseCTLine(data []byte) (ctEntry, bool) {
+	var probe struct {
+		LeafInput   string `json:"leaf_input"`
+		MessageType string `json:"message_type"`
+	}
+	if json.Unmarshal(data, &probe) != nil {
+		return ctEntry{}, false
+	}
+	if probe.LeafInput != "" {
+		var entry ctLogEntry
+		json.Unmarshal(data, &entry)
+		cert, seen, err := parseCTLogEntry(entry)
+		if err != nil {
+			return ctEntry{}, false
+		}
+		return ctEntry{Seen: seen, Cert: inspectCertificate(cert, *ctCertDays, seen)}, true
+	}
+	var message certstreamMessage
+	if probe.MessageType != "certificate_update" || json.Unmarshal(data, &message) != nil {
+		return ctEntry{}, false
+	}
+	leaf := message.Data.LeafCert
+	seen := unixSeconds(message.Data.Seen)
+	cert := &x509.Certificate{
+		Subject:   leaf.Subject.pkix(),
+		Issuer:    leaf.Issuer.pkix(),
+		NotBefore: unixSeconds(leaf.NotBefore),
+		NotAfter:  unixSeconds(leaf.NotAfter),
+		DNSNames:  leaf.AllDomains,
+	}
+	return ctEntry{Source: message.Data.Source.URL, Seen: seen, Cert: inspectCertificate(cert, *ctCertDays, seen)}, true
+}
+
+// reads recorded ct entries from a json lines file, emit returns false to stop reading
+func readCTFile(path string, emit func(ctEntry) bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if entry, ok := parseCTLine(scanner.Bytes()); ok {
+			if entry.Source == "" {
+				entry.Source = path
+			}
+			if !emit(entry) {
+				return nil
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// reads count entries from an RFC 6962 log starting at start, or the latest entries when start is negative
+func readCTLog(logURL string, start, count int64, emit func(ctEntry) bool) error {
+	logURL = strings.TrimSuffix(logURL, "/")
+	if start < 0 {
+		var sth struct {
+			TreeSize int64 `json:"tree_size"`
+		}
+		if err := getJSON(logURL+"/ct/v1/get-sth", &sth); err != nil {
+			return err
+		}
+		if start = sth.TreeSize - count; start < 0 {
+			start = 0
+		}
+	}
+	for end := start + count; start < end; {
+		last := start + ctBatchSize - 1
+		if last >= end {
+			last = end - 1
+		}
+		var response struct {
+			Entries []ctLogEntry `json:"entries"`
+		}
+		if err := getJSON(fmt.Sprintf("%s/ct/v1/get-entries?start=%d&end=%d", logURL, start, last), &response); err != nil {
+			return err
+		}
+		// logs may return fewer entries than requested
+		if len(response.Entries) == 0 {
+			return nil
+		}
+		for _, e := range response.Entries {
+			start++
+			cert, seen, err := parseCTLogEntry(e)
+			if err != nil {
+				continue
+			}
+			if !emit(ctEntry{Source: logURL, Seen: seen, Cert: inspectCertificate(cert, *ctCertDays, seen)}) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// reads ct entries from a certstream websocket until emit returns false or the stream ends
+func readCertstream(streamURL string, emit func(ctEntry) bool) error {
+	origin := "http://localhost/"
+	ws, err := websocket.Dial(streamURL, "", origin)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if entry, ok := parseCTLine(data); ok && !emit(entry) {
+			return nil
+		}
+	}
+}
+
+// scans the selected ct source, returning matching names as records
+func scanCT(matcher *ctMatcher, limit int64, hit func(Record)) error {
+	seen := make(map[string]bool)
+	read := int64(0)
+	emit := func(entry ctEntry) bool {
+		for _, name := range entry.Cert.SANs {
+			r, ok := matcher.match(name)
+			if !ok || seen[r.Domain] {
+				continue
+			}
+			seen[r.Domain] = true
+			r.setCertificate(entry.Cert)
+			r.CTSource = entry.Source
+			r.CTSeen = entry.Seen.UTC().Format(time.RFC3339)
+			hit(r)
+		}
+		read++
+		return limit <= 0 || read < limit
+	}
+	switch {
+	case *ctFile != "":
+		return readCTFile(*ctFile, emit)
+	case *ctLog != "":
+		if limit <= 0 {
+			limit = 256
+		}
+		return readCTLog(*ctLog, *ctStart, limit, emit)
+	default:
+		return readCertstream(*ctCertstream, emit)
+	}
+}
+
+// prints a ct match
+func printCTHit(writer *tabwriter.Writer, r *Record, verbose bool) {
+	line := r.Domain + "\t" + r.Target + "\t" + r.CTSeen + r.certLabel()
+	if verbose {
+		technique := r.Technique
+		if runtime.GOOS != "windows" {
+			technique = blue(technique)
+		}
+		line = technique + "\t" + line + "\t" + r.CTSource
+	}
+	fmt.Fprintln(writer, line)
+	writer.Flush()
+}
+
+// runs the ct mode, matching certificate transparency entries against permutations of the protected domains
+func runCT(args []string) {
+	ctSet.Usage = func() {
+		fmt.Println(ctDescription)
+		ctSet.PrintDefaults()
+		os.Exit(1)
+	}
+	ctSet.Parse(args)
+
+	sources := 0
+	for _, source := range []string{*ctFile, *ctLog, *ctCertstream} {
+		if source != "" {
+			sources++
+		}
+	}
+	if (*ctDomain == "") == (*ctList == "") || sources != 1 {
+		r.Printf("\nplease supply either option -d or -l and one of -file, -log or -certstream\n\n")
+		ctSet.Usage()
+	}
+	targets := []string{}
+	if *ctDomain != "" {
+		targets = append(targets, *ctDomain)
+	} else {
+		file, err := os.Open(*ctList)
+		if err != nil {
+			r.Printf("\nerror reading domains: %v\n\n", err)
+			os.Exit(1)
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				targets = append(targets, line)
+			}
+		}
+		file.Close()
+	}
+
+	// matches are written as they are found, a certstream is read until interrupted
+	output := newNDJSONOutput(os.Stdout)
+	w.Init(os.Stdout, 0, 22, 0, '\t', 0)
+	err := scanCT(newCTMatcher(targets), *ctCount, func(hit Record) {
+		if !*ctJSON {
+			printCTHit(w, &hit, *ctVerbose)
+		} else if err := output.write(&hit); err != nil {
+			r.Printf("\nerror writing results: %v\n\n", err)
+			os.Exit(1)
+		}
+	})
+	if err != nil {
+		r.Printf("\nerror reading ct entries: %v\n\n", err)
+		os.Exit(1)
+	}
+}
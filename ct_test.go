@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// expected matches of the recorded feed against example.com
+var ctFeedMatches = []struct {
+	domain    string
+	technique string
+	freeDV    bool
+}{
+	{"exampel.com", "transposition", true},
+	{"www.exampel.com", "transposition", true},
+	{"xn--secure-ample-yck0s.org", "skeleton", true},
+	{"examp1e-login.net", "skeleton", true},
+	{"mail.exampel.com", "transposition", true},
+}
+
+// scans the configured ct source and checks the hits against the recorded feed matches
+func checkCTFeed(t *testing.T, limit int64) []Record {
+	var hits []Record
+	if err := scanCT(newCTMatcher([]string{"example.com"}), limit, func(r Record) { hits = append(hits, r) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != len(ctFeedMatches) {
+		t.Fatalf("expected %d hits, got %d: %v", len(ctFeedMatches), len(hits), hits)
+	}
+	for i, expected := range ctFeedMatches {
+		hit := hits[i]
+		if hit.Domain != expected.domain || hit.Technique != expected.technique || hit.Target != "example.com" {
+			t.Errorf("expected %s %s, got %s %s (%s)", expected.technique, expected.domain, hit.Technique, hit.Domain, hit.Target)
+		}
+		if hit.CertFreeDV != expected.freeDV {
+			t.Errorf("%s: expected free dv %t", hit.Domain, expected.freeDV)
+		}
+	}
+	return hits
+}
+
+// selects a single ct source for the duration of the test
+func useCTSource(t *testing.T, file, log, certstream string) {
+	previous := []string{*ctFile, *ctLog, *ctCertstream}
+	*ctFile, *ctLog, *ctCertstream = file, log, certstream
+	t.Cleanup(func() { *ctFile, *ctLog, *ctCertstream = previous[0], previous[1], previous[2] })
+}
+
+func TestSkeleton(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		skeleton string
+	}{
+		{"example", "example"},
+		{"ехample", "example"},
+		{"xn--secure-ample-yck0s", "secureexample"},
+		{"examp1e", "example"},
+		{"paypa1", "paypal"},
+		{"rnicrosoft", "mlcrosoft"},
+		{"microsoft", "mlcrosoft"},
+	} {
+		if skeleton := skeleton(test.name); skeleton != test.skeleton {
+			t.Errorf("%s: expected skeleton %s, got %s", test.name, test.skeleton, skeleton)
+		}
+	}
+}
+
+func TestCTFile(t *testing.T) {
+	useCTSource(t, "testdata/ct_feed.jsonl", "", "")
+	hits := checkCTFeed(t, 0)
+	if hits[0].CTSource != "ct.googleapis.com/logs/argon2020/" || hits[0].CTSeen != "2020-10-06T16:00:01Z" {
+		t.Errorf("unexpected source %s seen %s", hits[0].CTSource, hits[0].CTSeen)
+	}
+	if hits[0].CertIssuer != "Let's Encrypt R3" || len(hits[0].CertSANs) != 2 {
+		t.Errorf("unexpected certificate %s %v", hits[0].CertIssuer, hits[0].CertSANs)
+	}
+}
+
+func TestCertstream(t *testing.T) {
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		file, err := os.Open("testdata/ct_feed.jsonl")
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			websocket.Message.Send(ws, scanner.Text())
+		}
+	}))
+	defer server.Close()
+	useCTSource(t, "", "", "ws"+strings.TrimPrefix(server.URL, "http"))
+	checkCTFeed(t, 0)
+}
+
+// encodes a certificate as the leaf input of an RFC 6962 x509 entry
+func ctLeafInput(der []byte, seen time.Time) string {
+	leaf := make([]byte, 12, 15+len(der))
+	binary.BigEndian.PutUint64(leaf[2:], uint64(seen.UnixNano()/int64(time.Millisecond)))
+	leaf = append(leaf, byte(len(der)>>16), byte(len(der)>>8), byte(len(der)))
+	leaf = append(leaf, der...)
+	return base64.StdEncoding.EncodeToString(append(leaf, 0, 0))
+}
+
+func TestCTLog(t *testing.T) {
+	now := time.Now()
+	var entries []ctLogEntry
+	for _, names := range [][]string{{"exampel.com", "www.exampel.com"}, {"unrelated.org"}, {"examp1e-login.net"}} {
+		cert := issueCertificate(t, "Let's Encrypt", pkix.Name{CommonName: names[0]}, now, names...)
+		entries = append(entries, ctLogEntry{LeafInput: ctLeafInput(cert.Certificate[0], now)})
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/log/ct/v1/get-sth", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tree_size":%d}`, len(entries)+10)
+	})
+	mux.HandleFunc("/log/ct/v1/get-entries", func(w http.ResponseWriter, r *http.Request) {
+		var start, end int
+		fmt.Sscan(r.URL.Query().Get("start"), &start)
+		fmt.Sscan(r.URL.Query().Get("end"), &end)
+		// serve a single entry per request to exercise short batches
+		var batch []ctLogEntry
+		if i := start - 10; i >= 0 && i < len(entries) && start <= end {
+			batch = entries[i : i+1]
+		}
+		json.NewEncoder(w).Encode(map[string][]ctLogEntry{"entries": batch})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	useCTSource(t, "", server.URL+"/log/", "")
+
+	var hits []Record
+	if err := scanCT(newCTMatcher([]string{"example.com"}), 3, func(r Record) { hits = append(hits, r) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 3 || hits[0].Domain != "exampel.com" || hits[2].Domain != "examp1e-login.net" {
+		t.Fatalf("unexpected hits %v", hits)
+	}
+	if !hits[0].CertFreeDV || !hits[0].CertRecent {
+		t.Errorf("expected a recent free dv certificate")
+	}
+}
+
+func TestCTCertDays(t *testing.T) {
+	line := []byte(`{"message_type":"certificate_update","data":{"seen":1602000001.5,"leaf_cert":{"subject":{"CN":"exampel.com"},` +
+		`"issuer":{"CN":"R3","O":"Let's Encrypt"},"not_before":1601900000,"not_after":1609766000,"all_domains":["exampel.com"]}}}`)
+	defer ctSet.Set("cert-days", fmt.Sprint(*ctCertDays))
+	for _, test := range []struct {
+		days   string
+		recent bool
+	}{{"0", false}, {"2", true}} {
+		if err := ctSet.Parse([]string{"-cert-days", test.days}); err != nil {
+			t.Fatal(err)
+		}
+		entry, ok := parseCTLine(line)
+		if !ok || entry.Cert.Recent != test.recent {
+			t.Errorf("expected recent %v with -cert-days %s, got %v", test.recent, test.days, entry.Cert.Recent)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNS lookup status classes
+const (
+	statusNoError  = "NOERROR"
+	statusNoData   = "NODATA"
+	statusNXDomain = "NXDOMAIN"
+	statusServFail = "SERVFAIL"
+	statusRefused  = "REFUSED"
+	statusTimeout  = "TIMEOUT"
+	statusError    = "ERROR"
+)
+
+var (
+	resolverAddr string
+	resolverOnce sync.Once
+	hostsTable   map[string][]string
+	hostsOnce    sync.Once
+	dnsPort      = "53"
+	dnsTimeout   = 3 * time.Second
+	dnsRetries   = 2
+)
+
+// dnsResult holds the outcome of a DNS query
+type dnsResult struct {
+	RCode       dnsmessage.RCode
+	Status      string
+	Answers     []dnsmessage.Resource
+	Authorities []dnsmessage.Resource
+	Err         error
+}
+
+// returns the addresses contained in the A records of the answer section
+func (d dnsResult) addrs() []string {
+	var addrs []string
+	for _, rr := range d.Answers {
+		if a, ok := rr.Body.(*dnsmessage.AResource); ok {
+			addrs = append(addrs, net.IP(a.A[:]).String())
+		}
+	}
+	return addrs
+}
+
+// returns the first address contained in the answer section
+func (d dnsResult) ip() string {
+	if addrs := d.addrs(); len(addrs) > 0 {
+		return addrs[0]
+	}
+	return ""
+}
+
+// returns the recursive resolver address, given with -resolver or read from /etc/resolv.conf,
+// empty when neither is available
+func systemResolver() string {
+	resolverOnce.Do(func() {
+		if resolverAddr != "" {
+			return
+		}
+		if *resolverFlag != "" {
+			resolverAddr = *resolverFlag
+			if _, _, err := net.SplitHostPort(resolverAddr); err != nil {
+				resolverAddr = net.JoinHostPort(resolverAddr, "53")
+			}
+			return
+		}
+		file, err := os.Open("/etc/resolv.conf")
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) > 1 && fields[0] == "nameserver" {
+				resolverAddr = net.JoinHostPort(fields[1], "53")
+				return
+			}
+		}
+	})
+	return resolverAddr
+}
+
+// checks that a recursive resolver is configured
+func validateResolver() error {
+	if systemResolver() == "" {
+		return fmt.Errorf("no dns resolver found in /etc/resolv.conf, please supply option -resolver")
+	}
+	return nil
+}
+
+// returns the path of the hosts file
+func hostsPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("SystemRoot"), "System32", "drivers", "etc", "hosts")
+	}
+	return "/etc/hosts"
+}
+
+// parses a hosts file into the IPv4 addresses of each lowercased name
+func parseHosts(path string) map[string][]string {
+	table := make(map[string][]string)
+	file, err := os.Open(path)
+	if err != nil {
+		return table
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || net.ParseIP(fields[0]).To4() == nil {
+			continue
+		}
+		for _, name := range fields[1:] {
+			name = strings.ToLower(strings.TrimSuffix(name, "."))
+			table[name] = append(table[name], fields[0])
+		}
+	}
+	return table
+}
+
+// returns the hosts file addresses of domain, read once per run
+func hostsAddrs(domain string) []string {
+	hostsOnce.Do(func() {
+		if hostsTable == nil {
+			hostsTable = parseHosts(hostsPath())
+		}
+	})
+	return hostsTable[strings.ToLower(strings.TrimSuffix(domain, "."))]
+}
+
+// returns a random query identifier
+func queryID() uint16 {
+	var id [2]byte
+	rand.Read(id[:])
+	return binary.BigEndian.Uint16(id[:])
+}
+
+// converts a domain to a fully qualified dns message name, punycoding IDNs
+func dnsName(domain string) (dnsmessage.Name, error) {
+	domain = strings.TrimSuffix(domain, ".")
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		// service labels such as _dmarc are valid dns names but not valid host names
+		if strings.IndexFunc(domain, func(c rune) bool { return c > 127 }) >= 0 {
+			return dnsmessage.Name{}, err
+		}
+		ascii = strings.ToLower(domain)
+	}
+	return dnsmessage.NewName(ascii + ".")
+}
+
+// sends a single query to server over udp, retrying over tcp on truncation
+func dnsExchange(server, domain string, qtype dnsmessage.Type, recursive bool) (*dnsmessage.Message, error) {
+	name, err := dnsName(domain)
+	if err != nil {
+		return nil, err
+	}
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: queryID(), RecursionDesired: recursive},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		msg, err := dnsRoundTrip("udp", server, packed, query.Header.ID)
+		if err == nil && msg.Header.Truncated {
+			msg, err = dnsRoundTrip("tcp", server, packed, query.Header.ID)
+		}
+		if nerr, ok := err.(net.Error); ok && nerr.Timeout() && attempt+1 < dnsRetries {
+			continue
+		}
+		return msg, err
+	}
+}
+
+// writes a packed query on the selected network and parses the reply
+func dnsRoundTrip(network, server string, packed []byte, id uint16) (*dnsmessage.Message, error) {
+	conn, err := net.DialTimeout(network, server, dnsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dnsTimeout))
+	buf := make([]byte, 65535)
+	var n int
+	if network == "tcp" {
+		framed := make([]byte, 2+len(packed))
+		binary.BigEndian.PutUint16(framed, uint16(len(packed)))
+		copy(framed[2:], packed)
+		if _, err = conn.Write(framed); err != nil {
+			return nil, err
+		}
+		if _, err = io.ReadFull(conn, buf[:2]); err != nil {
+			return nil, err
+		}
+		n = int(binary.BigEndian.Uint16(buf[:2]))
+		if _, err = io.ReadFull(conn, buf[:n]); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err = conn.Write(packed); err != nil {
+			return nil, err
+		}
+		for {
+			if n, err = conn.Read(buf); err != nil {
+				return nil, err
+			}
+			// ignore stray replies to other queries
+			if n >= 2 && binary.BigEndian.Uint16(buf[:2]) == id {
+				break
+			}
+		}
+	}
+	return parseDNSMessage(buf[:n])
+}
+
+// parses a dns reply, skipping resource types that cannot be decoded
+func parseDNSMessage(b []byte) (*dnsmessage.Message, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(b)
+	if err != nil {
+		return nil, err
+	}
+	msg := &dnsmessage.Message{Header: header}
+	if msg.Questions, err = p.AllQuestions(); err != nil {
+		return nil, err
+	}
+	sections := []struct {
+		header func() (dnsmessage.ResourceHeader, error)
+		body   func() (dnsmessage.Resource, error)
+		skip   func() error
+		dst    *[]dnsmessage.Resource
+	}{
+		{p.AnswerHeader, p.Answer, p.SkipAnswer, &msg.Answers},
+		{p.AuthorityHeader, p.Authority, p.SkipAuthority, &msg.Authorities},
+		{p.AdditionalHeader, p.Additional, p.SkipAdditional, &msg.Additionals},
+	}
+	for _, s := range sections {
+		for {
+			h, err := s.header()
+			if err == dnsmessage.ErrSectionDone {
+				break
+			}
+			if err != nil {
+				return msg, nil
+			}
+			switch h.Type {
+			case dnsmessage.TypeA, dnsmessage.TypeNS, dnsmessage.TypeCNAME, dnsmessage.TypeSOA,
+				dnsmessage.TypePTR, dnsmessage.TypeMX, dnsmessage.TypeTXT, dnsmessage.TypeAAAA:
+				rr, err := s.body()
+				if err != nil {
+					return msg, nil
+				}
+				*s.dst = append(*s.dst, rr)
+			default:
+				if err := s.skip(); err != nil {
+					return msg, nil
+				}
+			}
+		}
+	}
+	return msg, nil
+}
+
+// classifies a dns reply or transport error into a lookup status
+func classifyDNS(msg *dnsmessage.Message, err error, qtype dnsmessage.Type) dnsResult {
+	if err != nil {
+		var nerr net.Error
+		if errors.As(err, &nerr) && nerr.Timeout() {
+			return dnsResult{Status: statusTimeout, Err: err}
+		}
+		return dnsResult{Status: statusError, Err: err}
+	}
+	result := dnsResult{RCode: msg.Header.RCode, Answers: msg.Answers, Authorities: msg.Authorities}
+	switch msg.Header.RCode {
+	case dnsmessage.RCodeSuccess:
+		result.Status = statusNoData
+		for _, rr := range msg.Answers {
+			if rr.Header.Type == qtype {
+				result.Status = statusNoError
+				break
+			}
+		}
+	case dnsmessage.RCodeNameError:
+		result.Status = statusNXDomain
+	case dnsmessage.RCodeServerFailure:
+		result.Status = statusServFail
+	case dnsmessage.RCodeRefused:
+		result.Status = statusRefused
+	default:
+		result.Status = statusError
+	}
+	return result
+}
+
+// performs a recursive DNS query against the system resolver, A queries being answered
+// from the hosts file first like the operating system resolver does
+func dnsQuery(domain string, qtype dnsmessage.Type) dnsResult {
+	if qtype == dnsmessage.TypeA {
+		if addrs := hostsAddrs(domain); len(addrs) > 0 {
+			return hostsResult(domain, addrs)
+		}
+	}
+	msg, err := dnsExchange(systemResolver(), domain, qtype, true)
+	return classifyDNS(msg, err, qtype)
+}
+
+// returns a successful A lookup result holding hosts file addresses
+func hostsResult(domain string, addrs []string) dnsResult {
+	result := dnsResult{RCode: dnsmessage.RCodeSuccess, Status: statusNoError}
+	name, err := dnsName(domain)
+	if err != nil {
+		return dnsResult{Status: statusError, Err: err}
+	}
+	for _, addr := range addrs {
+		var a [4]byte
+		copy(a[:], net.ParseIP(addr).To4())
+		result.Answers = append(result.Answers, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET},
+			Body:   &dnsmessage.AResource{A: a},
+		})
+	}
+	return result
+}
+
+// domain registration verdicts
+const (
+	registered          = "registered"
+	unregistered        = "unregistered"
+	registrationUnknown = "unknown"
+)
+
+var parentServers sync.Map
+
+// returns the addresses of the authoritative name servers of a zone
+func zoneNameservers(zone string) []string {
+	if cached, ok := parentServers.Load(zone); ok {
+		return cached.([]string)
+	}
+	servers := []string{}
+	for _, rr := range dnsQuery(zone, dnsmessage.TypeNS).Answers {
+		if ns, ok := rr.Body.(*dnsmessage.NSResource); ok {
+			if ip := aLookup(ns.NS.String()).ip(); ip != "" {
+				servers = append(servers, net.JoinHostPort(ip, dnsPort))
+			}
+		}
+		if len(servers) == 3 {
+			break
+		}
+	}
+	// failed lookups are retried by the next candidate rather than cached for the whole run
+	if len(servers) > 0 {
+		parentServers.Store(zone, servers)
+	}
+	return servers
+}
+
+// extracts the name servers delegated to domain from a set of resources
+func delegatedNameservers(domain string, sections ...[]dnsmessage.Resource) []string {
+	var nameservers []string
+	for _, section := range sections {
+		for _, rr := range section {
+			ns, ok := rr.Body.(*dnsmessage.NSResource)
+			if ok && strings.EqualFold(strings.TrimSuffix(rr.Header.Name.String(), "."), domain) {
+				nameservers = append(nameservers, strings.ToLower(strings.TrimSuffix(ns.NS.String(), ".")))
+			}
+		}
+	}
+	return nameservers
+}
+
+// reports whether the resources hold the SOA record of domain
+func hasSOA(domain string, section []dnsmessage.Resource) bool {
+	for _, rr := range section {
+		if rr.Header.Type == dnsmessage.TypeSOA && strings.EqualFold(strings.TrimSuffix(rr.Header.Name.String(), "."), domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// determines if the registrable part of domain is delegated by its parent zone,
+// returns the verdict and the delegated name servers
+func registrationLookup(domain string) (string, []string) {
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(domain))
+	if err != nil {
+		return registrationUnknown, nil
+	}
+	suffix, _ := publicsuffix.PublicSuffix(registrable)
+
+	// ask the parent zone directly, a referral means the domain is registered
+	for _, server := range zoneNameservers(suffix) {
+		msg, err := dnsExchange(server, registrable, dnsmessage.TypeNS, false)
+		if err != nil {
+			continue
+		}
+		switch msg.Header.RCode {
+		case dnsmessage.RCodeNameError:
+			return unregistered, nil
+		case dnsmessage.RCodeSuccess:
+			if nameservers := delegatedNameservers(registrable, msg.Answers, msg.Authorities); len(nameservers) > 0 {
+				return registered, nameservers
+			}
+			if hasSOA(registrable, msg.Authorities) {
+				return registered, nil
+			}
+		}
+	}
+
+	// fall back to the recursive resolver
+	result := dnsQuery(registrable, dnsmessage.TypeNS)
+	switch result.Status {
+	case statusNoError:
+		return registered, delegatedNameservers(registrable, result.Answers)
+	case statusNoData:
+		if hasSOA(registrable, result.Authorities) {
+			return registered, nil
+		}
+	case statusNXDomain:
+		return unregistered, nil
+	}
+	return registrationUnknown, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"golang.org/x/net/dns/dnsmessage"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubZone maps a query name to the reply served by the dns stub
+type stubZone map[string]func(q dnsmessage.Question) dnsmessage.Message
+
+// starts a udp dns server answering from zone, returns its address
+func startDNSStub(t *testing.T, zone stubZone) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen on udp:", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if query.Unpack(buf[:n]) != nil || len(query.Questions) == 0 {
+				continue
+			}
+			q := query.Questions[0]
+			reply := dnsmessage.Message{Header: dnsmessage.Header{RCode: dnsmessage.RCodeNameError}}
+			if handler, ok := zone[strings.ToLower(q.Name.String())]; ok {
+				reply = handler(q)
+			}
+			reply.Header.ID = query.Header.ID
+			reply.Header.Response = true
+			reply.Questions = query.Questions
+			packed, err := reply.Pack()
+			if err == nil {
+				conn.WriteTo(packed, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// uses the stub server at addr as system resolver for the duration of the test
+func useResolver(t *testing.T, addr string) {
+	systemResolver()
+	previous := resolverAddr
+	resolverAddr = addr
+	t.Cleanup(func() { resolverAddr = previous })
+}
+
+func aReply(ip string) func(q dnsmessage.Question) dnsmessage.Message {
+	return func(q dnsmessage.Question) dnsmessage.Message {
+		var a [4]byte
+		copy(a[:], net.ParseIP(ip).To4())
+		return dnsmessage.Message{Answers: []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.AResource{A: a},
+		}}}
+	}
+}
+
+func rcodeReply(rcode dnsmessage.RCode) func(q dnsmessage.Question) dnsmessage.Message {
+	return func(q dnsmessage.Question) dnsmessage.Message {
+		return dnsmessage.Message{Header: dnsmessage.Header{RCode: rcode}}
+	}
+}
+
+func TestALookupStatus(t *testing.T) {
+	useResolver(t, startDNSStub(t, stubZone{
+		"resolved.test.":    aReply("192.0.2.1"),
+		"nodata.test.":      rcodeReply(dnsmessage.RCodeSuccess),
+		"servfail.test.":    rcodeReply(dnsmessage.RCodeServerFailure),
+		"refused.test.":     rcodeReply(dnsmessage.RCodeRefused),
+		"xn--tst-bma.test.": aReply("192.0.2.2"),
+	}))
+	for _, test := range []struct {
+		domain string
+		status string
+		ip     string
+	}{
+		{"resolved.test", statusNoError, "192.0.2.1"},
+		{"nodata.test", statusNoData, ""},
+		{"servfail.test", statusServFail, ""},
+		{"refused.test", statusRefused, ""},
+		{"missing.test", statusNXDomain, ""},
+		{"tést.test", statusNoError, "192.0.2.2"},
+	} {
+		result := aLookup(test.domain)
+		if result.Status != test.status {
+			t.Errorf("%s: expected status %s, got %s", test.domain, test.status, result.Status)
+		}
+		if result.ip() != test.ip {
+			t.Errorf("%s: expected ip '%s', got '%s'", test.domain, test.ip, result.ip())
+		}
+	}
+}
+
+func TestALookupTimeout(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen on udp:", err)
+	}
+	defer conn.Close()
+	useResolver(t, conn.LocalAddr().String())
+	timeout := dnsTimeout
+	dnsTimeout = 50 * time.Millisecond
+	defer func() { dnsTimeout = timeout }()
+	if result := aLookup("silent.test"); result.Status != statusTimeout {
+		t.Errorf("expected status %s, got %s", statusTimeout, result.Status)
+	}
+}
+
+func nsReply(authority bool, nameservers ...string) func(q dnsmessage.Question) dnsmessage.Message {
+	return func(q dnsmessage.Question) dnsmessage.Message {
+		var records []dnsmessage.Resource
+		for _, ns := range nameservers {
+			records = append(records, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeNS, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.NSResource{NS: dnsmessage.MustNewName(ns)},
+			})
+		}
+		if authority {
+			return dnsmessage.Message{Authorities: records}
+		}
+		return dnsmessage.Message{Answers: records}
+	}
+}
+
+func TestRegistrationLookup(t *testing.T) {
+	addr := startDNSStub(t, stubZone{
+		"test.":       nsReply(false, "ns.test."),
+		"ns.test.":    aReply("127.0.0.1"),
+		"taken.test.": nsReply(true, "ns1.parking.example.", "ns2.parking.example."),
+		"lame.test.":  nsReply(true, "ns.lame.example."),
+	})
+	useResolver(t, addr)
+	_, port, _ := net.SplitHostPort(addr)
+	previous := dnsPort
+	dnsPort = port
+	defer func() { dnsPort = previous }()
+
+	for _, test := range []struct {
+		domain      string
+		verdict     string
+		nameservers int
+		firstNS     string
+	}{
+		{"taken.test", registered, 2, "ns1.parking.example"},
+		{"www.taken.test", registered, 2, "ns1.parking.example"},
+		{"lame.test", registered, 1, "ns.lame.example"},
+		{"free.test", unregistered, 0, ""},
+	} {
+		verdict, nameservers := registrationLookup(test.domain)
+		if verdict != test.verdict {
+			t.Errorf("%s: expected %s, got %s", test.domain, test.verdict, verdict)
+		}
+		if len(nameservers) != test.nameservers {
+			t.Errorf("%s: expected %d name servers, got %d", test.domain, test.nameservers, len(nameservers))
+		} else if len(nameservers) > 0 && nameservers[0] != test.firstNS {
+			t.Errorf("%s: expected %s, got %s", test.domain, test.firstNS, nameservers[0])
+		}
+	}
+}
+
+func TestZoneNameserversFailure(t *testing.T) {
+	queries := 0
+	useResolver(t, startDNSStub(t, stubZone{
+		"flaky.": func(q dnsmessage.Question) dnsmessage.Message {
+			if queries++; queries == 1 {
+				return rcodeReply(dnsmessage.RCodeServerFailure)(q)
+			}
+			return nsReply(false, "ns.flaky.")(q)
+		},
+		"ns.flaky.": aReply("127.0.0.1"),
+	}))
+	defer parentServers.Delete("flaky")
+	if servers := zoneNameservers("flaky"); len(servers) != 0 {
+		t.Fatalf("expected no name servers after a failure, got %v", servers)
+	}
+	if servers := zoneNameservers("flaky"); len(servers) != 1 {
+		t.Errorf("expected the failure not to be cached, got %v", servers)
+	}
+}
+
+func TestParseHosts(t *testing.T) {
+	table := parseHosts("testdata/hosts")
+	if addrs := table["intranet.example.test"]; len(addrs) != 2 || addrs[0] != "10.0.0.5" || addrs[1] != "10.0.0.6" {
+		t.Errorf("expected both intranet addresses, got %v", addrs)
+	}
+	if _, ok := table["office"]; ok {
+		t.Error("expected comments to be ignored")
+	}
+	if _, ok := table["ip6.example.test"]; ok {
+		t.Error("expected IPv6 entries to be ignored")
+	}
+}
+
+func TestHostsLookup(t *testing.T) {
+	useResolver(t, startDNSStub(t, stubZone{"intranet.example.test.": aReply("192.0.2.1")}))
+	hostsAddrs("")
+	previous := hostsTable
+	hostsTable = parseHosts("testdata/hosts")
+	defer func() { hostsTable = previous }()
+	result := aLookup("Intranet.Example.test")
+	if result.Status != statusNoError || result.ip() != "10.0.0.5" {
+		t.Errorf("expected the hosts file address, got %s %v", result.Status, result.addrs())
+	}
+	if result := dnsQuery("intranet.example.test", dnsmessage.TypeMX); result.Status == statusNoError {
+		t.Errorf("expected only A queries answered from the hosts file, got %s", result.Status)
+	}
+}
@@ -3,27 +3,23 @@ package main
 import (
 	"archive/zip"
 	"bufio"
-	"encoding/csv"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/cavaliercoder/grab"
 	"github.com/fatih/color"
-	"github.com/likexian/whois-go"
-	"github.com/likexian/whois-parser-go"
 	"github.com/mholt/archiver/v3"
-	"github.com/oschwald/maxminddb-golang"
 	"github.com/tcnksm/go-latest"
+	"golang.org/x/net/dns/dnsmessage"
 	"golang.org/x/net/idna"
 	"golang.org/x/net/publicsuffix"
 	"io"
 	"log"
-	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"text/tabwriter"
@@ -34,6 +30,9 @@ import (
 // program version
 const version = "1.2.8"
 
+// similarity score from which landing pages are highlighted as probable clones
+const similarityThreshold = 70
+
 var (
 	githubTag = &latest.GithubTag{
 		Owner:             "netevert",
@@ -58,33 +57,102 @@ var (
 	includeSubDomains = newSet.Bool("i", false, "include subdomain")
 	resolve           = newSet.Bool("r", false, "resolve domain")
 	idn               = newSet.Bool("n", false, "idna format homograph domain")
-	outcsv            = newSet.Bool("csv", false, "output to csv")
-	outjson           = newSet.Bool("json", false, "output to json")
-	utilDescription   = "dnsmorph -d domain | -l domains_file [-girvuw] [-csv | -json]"
+	outcsv            = newSet.Bool("csv", false, "output to result.csv, alias of -o result.csv")
+	outjson           = newSet.Bool("json", false, "output json to stdout, alias of -o - -format json")
+	outFormat         = newSet.String("format", "", "format of -o outputs: "+strings.Join(formatNames(), ", ")+" (default inferred from extension)")
+	outputPaths       outputList
+	outputs           []*output
+	registeredOnly    = newSet.Bool("registered-only", false, "only output registered domains")
+	noCache           = newSet.Bool("no-cache", false, "disable whois cache")
+	refreshCache      = newSet.Bool("refresh", false, "refresh whois cache entries")
+	cacheTTL          = newSet.Duration("cache-ttl", 24*time.Hour, "whois cache time to live")
+	geoipDB           = newSet.String("geoip-db", "", "geolocation database filepath (default $DNSMORPH_GEOIP_DB or data/GeoLite2-City.mmdb)")
+	allowlist         = newSet.String("allowlist", "", "owned domains allowlist filepath")
+	hideOwned         = newSet.Bool("hide-owned", false, "hide domains matching the allowlist")
+	httpflag          = newSet.Bool("http", false, "probe http and https landing pages")
+	httpTimeout       = newSet.Duration("http-timeout", 10*time.Second, "http probe timeout")
+	userAgent         = newSet.String("user-agent", "Mozilla/5.0 (compatible; dnsmorph/"+version+")", "http probe user agent")
+	tlsflag           = newSet.Bool("tls", false, "inspect tls certificates")
+	certDays          = newSet.Int("cert-days", 30, "days within which a certificate is reported as recent")
+	tlsTimeout        = newSet.Duration("tls-timeout", 5*time.Second, "tls certificate lookup timeout")
+	mailflag          = newSet.Bool("mail", false, "lookup mail exchangers, spf and dmarc records")
+	smtpflag          = newSet.Bool("smtp", false, "probe smtp servers for banner and catch-all (implies -mail)")
+	blockFilter       = newSet.String("block-filter", "registered", "comma separated criteria of blocklist outputs: registered, resolved, all")
+	minScore          = newSet.Int("min-score", 0, "minimum similarity score of blocklist outputs (implies -http)")
+	rpzAction         = newSet.String("rpz-action", "nxdomain", "rpz policy action: nxdomain, nodata, drop, passthru, an address or a host name")
+	rpzSOA            = newSet.String("rpz-soa", "localhost. hostmaster.localhost.", "rpz zone primary name server and hostmaster mailbox")
+	sinkhole          = newSet.String("sinkhole", "0.0.0.0", "address of hosts and dnsmasq blocklist outputs")
+	sidBase           = newSet.Int("sid-base", 1000000, "first signature id of suricata and snort rules, which take 1000000 ids")
+	resolverFlag      = newSet.String("resolver", "", "recursive dns resolver address (default from /etc/resolv.conf)")
+	templateFile      = newSet.String("template", "", "template filepath rendering each record, or the result set when it defines a results template")
+	parkingSigs       = newSet.String("parking-signatures", "", "parking signatures filepath (default embedded set)")
+	asnDB             = newSet.String("asn-db", "", "ASN database filepath (default $DNSMORPH_ASN_DB or data/GeoLite2-ASN.mmdb)")
+	utilDescription   = "dnsmorph -d domain | -l domains_file [-girvuw] [-o path]... [-format format]"
 	banner            = `
 ╔╦╗╔╗╔╔═╗╔╦╗╔═╗╦═╗╔═╗╦ ╦
  ║║║║║╚═╗║║║║ ║╠╦╝╠═╝╠═╣
 ═╩╝╝╚╝╚═╝╩ ╩╚═╝╩╚═╩  ╩ ╩`  // Calvin S on http://patorjk.com/
 )
 
-// GeoIPRecord struct
-type GeoIPRecord struct {
-	City struct {
-		Names map[string]string `maxminddb:"names"`
-	} `maxminddb:"city"`
-	Country struct {
-		IsoCode string `maxminddb:"iso_code"`
-	} `maxminddb:"country"`
-}
-
 // Record struct
 type Record struct {
-	Technique         string `json:"technique"`
-	Domain            string `json:"domain"`
-	A                 string `json:"a_record"`
-	Geolocation       string `json:"geolocation"`
-	WhoisCreation     string `json:"whoiscreation"`
-	WhoisModification string `json:"whoismodification"`
+	Target            string     `json:"target"`
+	Technique         string     `json:"technique"`
+	Domain            string     `json:"domain"`
+	A                 string     `json:"a_record"`
+	Geolocation       string     `json:"geolocation"`
+	WhoisCreation     string     `json:"whoiscreation"`
+	WhoisModification string     `json:"whoismodification"`
+	Status            string     `json:"status"`
+	Registration      string     `json:"registration"`
+	NS                []string   `json:"ns"`
+	WhoisExpiration   string     `json:"whoisexpiration"`
+	Registrar         string     `json:"registrar"`
+	RegistrantOrg     string     `json:"registrant_org"`
+	WhoisStatus       []string   `json:"whoisstatus"`
+	WhoisNameservers  []string   `json:"whoisnameservers"`
+	AbuseContact      string     `json:"abuse_contact"`
+	WhoisCached       bool       `json:"whois_cached"`
+	CountryCode       string     `json:"country_code"`
+	CountryName       string     `json:"country_name"`
+	Subdivision       string     `json:"subdivision"`
+	City              string     `json:"city"`
+	Latitude          float64    `json:"latitude"`
+	Longitude         float64    `json:"longitude"`
+	AccuracyRadius    uint16     `json:"accuracy_radius"`
+	ASN               uint       `json:"asn"`
+	ASOrg             string     `json:"as_org"`
+	IPs               []string   `json:"ips"`
+	PTR               string     `json:"ptr"`
+	InfraMatch        string     `json:"infra_match"`
+	Defensive         bool       `json:"probably_defensive"`
+	Owned             bool       `json:"owned"`
+	HTTP              *HTTPProbe `json:"http,omitempty"`
+	HTTPS             *HTTPProbe `json:"https,omitempty"`
+	Similarity        int        `json:"similarity"`
+	LoginForm         bool       `json:"login_form"`
+	PasswordField     bool       `json:"password_field"`
+	Parked            bool       `json:"parked"`
+	ForSale           bool       `json:"for_sale"`
+	ParkedReason      string     `json:"parked_reason"`
+	CertSubject       string     `json:"cert_subject"`
+	CertSANs          []string   `json:"cert_sans"`
+	CertIssuer        string     `json:"cert_issuer"`
+	CertNotBefore     string     `json:"cert_not_before"`
+	CertNotAfter      string     `json:"cert_not_after"`
+	CertFreeDV        bool       `json:"cert_free_dv"`
+	CertRecent        bool       `json:"cert_recent"`
+	MX                []string   `json:"mx"`
+	MXFallback        bool       `json:"mx_fallback"`
+	SMTPBanner        string     `json:"smtp_banner"`
+	CatchAll          bool       `json:"catch_all"`
+	SPF               string     `json:"spf"`
+	SPFAll            string     `json:"spf_all"`
+	DMARC             string     `json:"dmarc"`
+	DMARCPolicy       string     `json:"dmarc_policy"`
+	CTSource          string     `json:"ct_source,omitempty"`
+	CTSeen            string     `json:"ct_seen,omitempty"`
+	RedirectsToTarget bool       `json:"redirects_to_target"`
 }
 
 // Target struct
@@ -99,16 +167,106 @@ type OutJSON struct {
 	Results []Record `json:"results"`
 }
 
-// prints Record data
+// prints Record data through the default templates
 func (r *Record) printRecordData(writer *tabwriter.Writer, verbose bool) {
+	tmpl := recordTemplate
 	if verbose != false {
-		fmt.Fprintln(writer, r.Technique+"\t"+r.Domain+"\t"+r.A+
-			"\t"+r.WhoisCreation+"\t"+r.WhoisModification+"\t"+r.Geolocation)
-		writer.Flush()
-	} else {
-		fmt.Fprintln(writer, r.Domain+"\t"+r.A+"\t"+r.WhoisCreation+"\t"+r.WhoisModification+"\t"+r.Geolocation)
-		writer.Flush()
+		tmpl = verboseTemplate
+	}
+	if err := tmpl.Execute(writer, r); err != nil {
+		log.Fatal(err)
+	}
+	writer.Flush()
+}
+
+// returns the registration verdict, flagging owned, defensive, parked and for sale domains
+func (r *Record) registrationLabel() string {
+	if r.Owned {
+		return strings.TrimSpace(r.Registration + " (owned)")
+	}
+	if r.Defensive {
+		return r.Registration + " (probably ours, same " + r.InfraMatch + ")"
 	}
+	// a parking page serving a login form or a clone of the target is no longer benign
+	if r.LoginForm || r.Similarity >= similarityThreshold {
+		return r.Registration
+	}
+	if r.ForSale {
+		return strings.TrimSpace(r.Registration + " (for sale)")
+	}
+	if r.Parked {
+		return strings.TrimSpace(r.Registration + " (parked)")
+	}
+	return r.Registration
+}
+
+// returns the content similarity column, highlighting probable clones and login pages
+func (r *Record) contentLabel() string {
+	if r.HTTP == nil && r.HTTPS == nil {
+		return ""
+	}
+	if r.RedirectsToTarget {
+		return "\tredirects to target"
+	}
+	label := fmt.Sprintf("%d%% similar", r.Similarity)
+	if r.LoginForm {
+		label += " login form"
+	} else if r.PasswordField {
+		label += " password field"
+	}
+	if (r.Similarity >= similarityThreshold || r.LoginForm) && runtime.GOOS != "windows" {
+		label = red(label)
+	}
+	return "\t" + label
+}
+
+// returns the certificate column, highlighting recently issued free DV certificates
+func (r *Record) certLabel() string {
+	if r.CertIssuer == "" {
+		return ""
+	}
+	label := r.CertIssuer
+	if r.CertFreeDV && r.CertRecent {
+		label = "new free DV certificate (" + r.CertIssuer + ")"
+		if runtime.GOOS != "windows" {
+			label = red(label)
+		}
+	}
+	return "\t" + label
+}
+
+// csvColumns names the fields of csvData rows
+var csvColumns = []string{"technique", "domain", "a_record", "geolocation", "whoiscreation", "whoismodification", "status",
+	"registration", "ns", "whoisexpiration", "registrar", "registrant_org",
+	"whoisstatus", "whoisnameservers", "abuse_contact", "target",
+	"country_code", "country_name", "subdivision", "city", "latitude", "longitude",
+	"accuracy_radius", "asn", "as_org", "ips", "ptr", "infra_match", "probably_defensive",
+	"owned",
+	"http_status", "http_final_url", "http_title", "http_server", "http_content_length",
+	"https_status", "https_final_url", "https_title", "https_server", "https_content_length",
+	"similarity", "login_form", "password_field",
+	"parked", "for_sale", "parked_reason",
+	"cert_subject", "cert_sans", "cert_issuer", "cert_not_before", "cert_not_after",
+	"cert_free_dv", "cert_recent", "ct_source", "ct_seen",
+	"mx", "mx_fallback", "smtp_banner", "catch_all",
+	"spf", "spf_all", "dmarc", "dmarc_policy", "redirects_to_target"}
+
+// returns Record data as a csv row
+func (r *Record) csvData() []string {
+	data := []string{r.Technique, r.Domain, r.A, r.Geolocation, r.WhoisCreation, r.WhoisModification, r.Status,
+		r.Registration, strings.Join(r.NS, " "), r.WhoisExpiration, r.Registrar, r.RegistrantOrg,
+		strings.Join(r.WhoisStatus, " "), strings.Join(r.WhoisNameservers, " "), r.AbuseContact, r.Target,
+		r.CountryCode, r.CountryName, r.Subdivision, r.City, r.coordinate(r.Latitude), r.coordinate(r.Longitude),
+		r.accuracyRadius(), r.asn(), r.ASOrg, strings.Join(r.IPs, " "), r.PTR, r.InfraMatch, strconv.FormatBool(r.Defensive),
+		strconv.FormatBool(r.Owned)}
+	data = append(data, r.HTTP.csvData()...)
+	data = append(data, r.HTTPS.csvData()...)
+	return append(data, strconv.Itoa(r.Similarity), strconv.FormatBool(r.LoginForm), strconv.FormatBool(r.PasswordField),
+		strconv.FormatBool(r.Parked), strconv.FormatBool(r.ForSale), r.ParkedReason,
+		r.CertSubject, strings.Join(r.CertSANs, " "), r.CertIssuer, r.CertNotBefore, r.CertNotAfter,
+		strconv.FormatBool(r.CertFreeDV), strconv.FormatBool(r.CertRecent), r.CTSource, r.CTSeen,
+		strings.Join(r.MX, " "), strconv.FormatBool(r.MXFallback), r.SMTPBanner, strconv.FormatBool(r.CatchAll),
+		r.SPF, r.SPFAll, r.DMARC, r.DMARCPolicy, strconv.FormatBool(r.RedirectsToTarget))
 }
 
 // checks if new version of dnsmorph is available
@@ -299,6 +457,7 @@ func setup() {
 		os.Exit(1)
 	}
 
+	newSet.Var(&outputPaths, "o", "output filepath, - for stdout, optionally prefixed with a format, e.g. dnsmasq:blocked.conf (repeatable)")
 	newSet.Parse(os.Args[1:])
 
 	// workaround to suppress glog errors, as per https://github.com/kubernetes/kubernetes/issues/17162#issuecomment-225596212
@@ -322,8 +481,92 @@ func setup() {
 		os.Exit(1)
 	}
 
-	if *outjson != false && *outcsv != false {
-		r.Printf("\nplease supply either csv or json ouput\n\n")
+	// -csv and -json are kept as aliases of -o result.csv and -o - -format json
+	if *outcsv != false {
+		outputs = append(outputs, &output{path: "result.csv", format: "csv"})
+	}
+	if *outjson != false {
+		outputs = append(outputs, &output{path: "-", format: "json"})
+	}
+	for _, value := range outputPaths {
+		path, format, err := parseOutputPath(value, *outFormat)
+		if err != nil {
+			r.Printf("\n%v\n\n", err)
+			fmt.Println(utilDescription)
+			newSet.PrintDefaults()
+			os.Exit(1)
+		}
+		outputs = append(outputs, &output{path: path, format: format})
+	}
+	// -template writes to stdout unless a template output is supplied
+	if *templateFile != "" {
+		tmpl, err := loadTemplate(*templateFile)
+		if err != nil {
+			r.Printf("\nerror reading template: %v\n\n", err)
+			os.Exit(1)
+		}
+		userTemplate = tmpl
+		if !templateOutputs(outputs) {
+			outputs = append(outputs, &output{path: "-", format: "template"})
+		}
+	} else if templateOutputs(outputs) {
+		r.Printf("\nplease supply the template of template outputs with option -template\n\n")
+		os.Exit(1)
+	}
+	paths := make(map[string]bool)
+	for _, o := range outputs {
+		if paths[o.path] {
+			r.Printf("\noutput %s supplied more than once\n\n", o.path)
+			os.Exit(1)
+		}
+		paths[o.path] = true
+	}
+
+	if err := validateBlocklistOptions(); err != nil {
+		r.Printf("\n%v\n\n", err)
+		os.Exit(1)
+	}
+	if err := validateRuleOptions(); err != nil {
+		r.Printf("\n%v\n\n", err)
+		os.Exit(1)
+	}
+	// blocklist and rule outputs rely on the registration, address and similarity lookups
+	if *minScore > 0 {
+		*httpflag = true
+	}
+	if registrationOutputs(outputs) {
+		*resolve = true
+	}
+
+	if *smtpflag {
+		*mailflag = true
+	}
+
+	if *registeredOnly || *httpflag || *tlsflag || *mailflag {
+		*resolve = true
+	}
+	if *resolve || *geolocate {
+		if err := validateResolver(); err != nil {
+			r.Printf("\n%v\n\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var err error
+	if *allowlist != "" {
+		if ownership, err = loadAllowlist(*allowlist); err != nil {
+			r.Printf("\nerror reading allowlist: %v\n\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if parking, err = loadParkingSignatures(*parkingSigs); err != nil {
+		r.Printf("\nerror reading parking signatures: %v\n\n", err)
+		os.Exit(1)
+	}
+
+	if *hideOwned && ownership == nil {
+		r.Printf("\nplease supply an allowlist with option -allowlist\n\n")
 		fmt.Println(utilDescription)
 		newSet.PrintDefaults()
 		os.Exit(1)
@@ -339,83 +582,85 @@ func countChar(word string) map[rune]int {
 	return count
 }
 
-// performs an A record DNS lookup
-func aLookup(Domain string) string {
-	ip, err := net.ResolveIPAddr("ip4", Domain)
-	if err != nil {
-		return ""
-
-	}
-	return ip.String() // todo: fix to return only one IP
-}
-
-// performs a geolocation lookup on input IP, returns country + city
-func geoLookup(inputIP string) string {
-	if inputIP != "" {
-		db, err := maxminddb.Open("data/GeoLite2-City.mmdb")
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer db.Close()
-		ip := net.ParseIP(inputIP)
-		var record GeoIPRecord
-		err = db.Lookup(ip, &record)
-		if err != nil {
-			log.Fatal(err)
-		}
-		return record.Country.IsoCode + " " + record.City.Names["en"]
-	}
-	return ""
-}
-
-// performs a whois lookup on input IP, return creation and modification date
-func whoisLookup(inputDomain string) []string {
-	var data []string
-	if inputDomain != "" {
-		whoisRaw, _ := whois.Whois(inputDomain)
-		result, err := whoisparser.Parse(whoisRaw)
-		if err == nil {
-
-			// Add the domain created date
-			data = append(data, result.Domain.CreatedDate)
-
-			// Print the domain modification date
-			data = append(data, result.Domain.UpdatedDate)
-		}
-	}
-	return data
+// performs an A record DNS lookup, returns the addresses along with the rcode and status
+func aLookup(Domain string) dnsResult {
+	return dnsQuery(Domain, dnsmessage.TypeA)
 }
 
 // performs lookups on individual records
-func doLookups(Technique, Domain, tld string, out chan<- Record, resolve, geolocate, whoisflag bool) {
+func doLookups(Technique, Domain, tld, target string, out chan<- Record, resolve, geolocate, whoisflag bool) {
 	defer wg.Done()
 	r := new(Record)
+	r.Target = target
 	r.Technique = Technique
 	r.Domain = Domain + "." + tld
-	if resolve {
-		r.A = aLookup(r.Domain)
-	}
-	if geolocate {
-		r.Geolocation = geoLookup(aLookup(r.Domain))
+	if resolve || geolocate {
+		lookup := aLookup(r.Domain)
+		r.Status = lookup.Status
+		if resolve {
+			r.A = lookup.ip()
+			r.IPs = lookup.addrs()
+			r.Registration, r.NS = registrationLookup(r.Domain)
+			if *mailflag && r.Status != statusNXDomain {
+				r.MX, r.MXFallback = mxLookup(r.Domain)
+				r.SPF, r.SPFAll = spfLookup(r.Domain)
+				r.DMARC, r.DMARCPolicy = dmarcLookup(r.Domain)
+				if *smtpflag && len(r.MX) > 0 {
+					r.SMTPBanner, r.CatchAll, _ = smtpProbe(r.MX[0], r.Domain)
+				}
+			}
+			if r.A != "" {
+				r.PTR = ptrLookup(r.A)
+				r.InfraMatch, r.Defensive = originInfrastructure(target).match(target, r.PTR, r.IPs)
+				if *httpflag {
+					r.HTTP, r.HTTPS = httpLookup(r.Domain)
+					// a page redirecting to the protected domain is the brand site itself, not a clone
+					if redirectsToTarget(target, r.HTTPS, r.HTTP) {
+						r.RedirectsToTarget = true
+					} else if body := probeBody(r.HTTPS, r.HTTP); body != nil {
+						page := analysePage(body)
+						r.Similarity = page.similarity(originPageFeatures(target))
+						r.LoginForm, r.PasswordField = page.LoginForm, page.PasswordField
+					}
+				}
+				if *tlsflag {
+					if cert, err := certLookup(r.A, r.Domain); err == nil {
+						r.setCertificate(cert)
+					}
+				}
+			}
+		}
+		if geolocate {
+			r.setGeolocation(geoLookup(lookup.ip()))
+		}
 	}
 	if whoisflag {
-		record := whoisLookup(r.Domain)
-		if len(record) > 0 {
-			r.WhoisCreation = record[0]
-			r.WhoisModification = record[1]
-		} else {
-			r.WhoisCreation = ""
-			r.WhoisModification = ""
+		record, cached, err := cachedWhoisLookup(r.Domain, !*noCache, *refreshCache, *cacheTTL)
+		if err == nil {
+			r.WhoisCached = cached
+			r.WhoisCreation = record.Created
+			r.WhoisModification = record.Updated
+			r.WhoisExpiration = record.Expiry
+			r.Registrar = record.Registrar
+			r.RegistrantOrg = record.RegistrantOrg
+			r.WhoisStatus = record.Status
+			r.WhoisNameservers = record.Nameservers
+			r.AbuseContact = record.AbuseEmail
 		}
 	}
+	if resolve || whoisflag {
+		r.Parked, r.ForSale, r.ParkedReason = parking.classify(r)
+	}
+	releaseBodies(r.HTTP, r.HTTPS)
+	r.Owned = ownership.owns(r)
 	out <- *r
 }
 
 // runs bulk lookups on list of domains
-func runLookups(technique string, results []string, tld string, out chan<- Record, resolve, geolocate, whoisflag bool) {
+func runLookups(technique string, results []string, tld, target string, out chan<- Record, resolve, geolocate, whoisflag bool) {
 	for _, r := range results {
 		wg.Add(1)
-		go doLookups(technique, r, tld, out, resolve, geolocate, whoisflag)
+		go doLookups(technique, r, tld, target, out, resolve, geolocate, whoisflag)
 	}
 }
 
@@ -449,40 +694,43 @@ func processInput(input string) (sanitizedDomain, tld string) {
 }
 
 // helper function to print permutation report and miscellaneous information
-func printReport(technique string, results []string, tld string) {
+func printReport(technique string, results []string, tld, target string) {
 	out := make(chan Record)
 	w.Init(os.Stdout, 0, 22, 0, '\t', 0)
 	switch {
 	case *resolve == true && *geolocate == true && *whoisflag == true:
-		runLookups(technique, results, tld, out, *resolve, *geolocate, *whoisflag)
+		runLookups(technique, results, tld, target, out, *resolve, *geolocate, *whoisflag)
 	case *verbose == true && *resolve == true && *geolocate == true && *whoisflag == true:
-		runLookups(technique, results, tld, out, *resolve, *geolocate, *whoisflag)
+		runLookups(technique, results, tld, target, out, *resolve, *geolocate, *whoisflag)
 	case *verbose == true && *resolve == true && *whoisflag == true:
-		runLookups(technique, results, tld, out, *resolve, false, *whoisflag)
+		runLookups(technique, results, tld, target, out, *resolve, false, *whoisflag)
 	case *verbose == true && *geolocate == true && *whoisflag == true:
-		runLookups(technique, results, tld, out, false, *geolocate, *whoisflag)
+		runLookups(technique, results, tld, target, out, false, *geolocate, *whoisflag)
 	case *verbose == true && *whoisflag == true:
-		runLookups(technique, results, tld, out, false, false, *whoisflag)
+		runLookups(technique, results, tld, target, out, false, false, *whoisflag)
 	case *resolve == true && *whoisflag == true:
-		runLookups(technique, results, tld, out, *resolve, false, *whoisflag)
+		runLookups(technique, results, tld, target, out, *resolve, false, *whoisflag)
 	case *geolocate == true && *whoisflag == true:
-		runLookups(technique, results, tld, out, false, *geolocate, *whoisflag)
+		runLookups(technique, results, tld, target, out, false, *geolocate, *whoisflag)
 	case *verbose == true && *resolve == true && *geolocate == true:
-		runLookups(technique, results, tld, out, *resolve, *geolocate, false)
+		runLookups(technique, results, tld, target, out, *resolve, *geolocate, false)
 	case *verbose == true && *geolocate == true:
-		runLookups(technique, results, tld, out, false, *geolocate, false)
+		runLookups(technique, results, tld, target, out, false, *geolocate, false)
 	case *verbose == true && *resolve == true:
-		runLookups(technique, results, tld, out, *resolve, *geolocate, false)
+		runLookups(technique, results, tld, target, out, *resolve, *geolocate, false)
 	case *resolve == true && *geolocate == true:
-		runLookups(technique, results, tld, out, *resolve, *geolocate, false)
+		runLookups(technique, results, tld, target, out, *resolve, *geolocate, false)
 	case *geolocate == true:
-		runLookups(technique, results, tld, out, false, *geolocate, false)
+		runLookups(technique, results, tld, target, out, false, *geolocate, false)
 	case *resolve == true:
-		runLookups(technique, results, tld, out, *resolve, *geolocate, false)
+		runLookups(technique, results, tld, target, out, *resolve, *geolocate, false)
 	case *whoisflag == true:
-		runLookups(technique, results, tld, out, false, false, *whoisflag)
+		runLookups(technique, results, tld, target, out, false, false, *whoisflag)
 	case *verbose == true:
 		for _, result := range results {
+			if *hideOwned && ownership.owns(&Record{Domain: result + "." + tld}) {
+				continue
+			}
 			if (*idn == true && technique == "homograph") {
 				idn_result, err := idna.Lookup.ToASCII(result)
 				if err == nil {
@@ -494,6 +742,9 @@ func printReport(technique string, results []string, tld string) {
 		}
 	case *verbose == false && *resolve == false:
 		for _, result := range results {
+			if *hideOwned && ownership.owns(&Record{Domain: result + "." + tld}) {
+				continue
+			}
 			if (*idn == true && technique == "homograph") {
 				idn_result, err := idna.Lookup.ToASCII(result)
 				if err == nil {
@@ -506,6 +757,9 @@ func printReport(technique string, results []string, tld string) {
 	}
 	go monitorWorker(wg, out)
 	for r := range out {
+		if skipRecord(&r) {
+			continue
+		}
 		r.printRecordData(w, *verbose)
 	}
 }
@@ -531,7 +785,7 @@ func printOutputInfo(results [][]string) {
 	y.Printf("%s ", "[*]")
 	fmt.Printf("%s", "lookups selected: ")
 	if *resolve != false {
-		lookups = append(lookups, "a record")
+		lookups = append(lookups, "a record", "registration")
 	}
 	if *geolocate != false {
 		lookups = append(lookups, "geolocation")
@@ -539,105 +793,121 @@ func printOutputInfo(results [][]string) {
 	if *whoisflag != false {
 		lookups = append(lookups, "whois lookup")
 	}
+	if *httpflag != false {
+		lookups = append(lookups, "http probe")
+	}
+	if *tlsflag != false {
+		lookups = append(lookups, "tls certificate")
+	}
+	if *mailflag != false {
+		lookups = append(lookups, "mail")
+	}
+	if *smtpflag != false {
+		lookups = append(lookups, "smtp probe")
+	}
 	for _, lookup := range lookups {
 		y.Printf("[%s] ", lookup)
 	}
 	fmt.Printf("\n")
 }
 
+// helper function to filter out records excluded by the output options
+func skipRecord(r *Record) bool {
+	return (*registeredOnly && r.Registration != registered) || (*hideOwned && r.Owned)
+}
+
 // helper function to wait for goroutines collection to finish and close channel
 func monitorWorker(wg *sync.WaitGroup, channel chan Record) {
 	wg.Wait()
 	close(channel)
 }
 
-// outputs results data to a csv file
+// outputs results data to the selected outputs, printing a table on screen unless an output writes to stdout
 func outputToFile(targets []string) {
 	// create results list
 	out := make(chan Record)
 	results := [][]string{}
 	for _, target := range targets {
 		sanitizedDomain, tld := processInput(target)
-		for _, t := range []Target{
-			{"transposition", sanitizedDomain, transpositionAttack},
-			{"addition", sanitizedDomain, additionAttack},
-			{"vowelswap", sanitizedDomain, vowelswapAttack},
-			{"subdomain", sanitizedDomain, subdomainAttack},
-			{"replacement", sanitizedDomain, replacementAttack},
-			{"repetition", sanitizedDomain, repetitionAttack},
-			{"omission", sanitizedDomain, omissionAttack},
-			{"hyphenation", sanitizedDomain, hyphenationAttack},
-			{"bitsquatting", sanitizedDomain, bitsquattingAttack},
-			{"homograph", sanitizedDomain, homographAttack},
-			{"doppelganger", sanitizedDomain, doppelgangerAttack}} {
+		for _, t := range permutationTargets(sanitizedDomain) {
 			for _, r := range t.Function(t.TargetDomain) {
-				results = append(results, []string{r + "." + tld, t.Technique})
+				results = append(results, []string{r, tld, t.Technique, target})
 			}
 		}
 	}
+	for _, o := range outputs {
+		if err := o.open(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	table := !stdoutOutput(outputs)
+	if table && *verbose != false {
+		printOutputInfo(results)
+	}
 	for _, r := range results {
 		wg.Add(1)
-		s := strings.Split(r[0], ".")
-		domain, tld := s[0], s[1]
-		go doLookups(r[1], domain, tld, out, *resolve, *geolocate, *whoisflag)
+		go doLookups(r[2], r[0], r[1], r[3], out, *resolve, *geolocate, *whoisflag)
 	}
 	go monitorWorker(wg, out)
-	if *outcsv != false {
-		if *verbose != false {
-			printOutputInfo(results)
-		}
-		file, err := os.Create("result.csv")
-		if err != nil {
-			log.Fatal(err)
+	w.Init(os.Stdout, 0, 22, 0, '\t', 0)
+	for r := range out {
+		if skipRecord(&r) {
+			continue
 		}
-		defer file.Close()
-		writer := csv.NewWriter(file)
-		defer writer.Flush()
-		for r := range out {
-			var data = []string{r.Technique, r.Domain, r.A, r.Geolocation, r.WhoisCreation, r.WhoisModification}
-			err := writer.Write(data)
-			if err != nil {
+		for _, o := range outputs {
+			if err := o.writer.write(&r); err != nil {
 				log.Fatal(err)
 			}
 		}
-		if *verbose != false {
-			y.Printf("%s ", "[*]")
-			g.Printf("done")
-		} else {
-			g.Printf("done")
+		if table {
+			r.printRecordData(w, *verbose)
 		}
 	}
-	if *outjson != false {
-		var output OutJSON
-		for r := range out {
-			output.Results = append(output.Results, r)
-		}
-		data, err := json.Marshal(output)
-		if err != nil {
+	for _, o := range outputs {
+		if err := o.close(); err != nil {
 			log.Fatal(err)
 		}
-		fmt.Printf("%s\n", data)
+		if table && *verbose != false {
+			y.Printf("%s ", "[*]")
+			g.Printf("%s output written to %s\n", o.format, o.path)
+		}
 	}
 }
 
+// returns the permutation attacks to be performed against a domain
+func permutationTargets(sanitizedDomain string) []Target {
+	return []Target{
+		{"transposition", sanitizedDomain, transpositionAttack},
+		{"addition", sanitizedDomain, additionAttack},
+		{"vowelswap", sanitizedDomain, vowelswapAttack},
+		{"subdomain", sanitizedDomain, subdomainAttack},
+		{"replacement", sanitizedDomain, replacementAttack},
+		{"repetition", sanitizedDomain, repetitionAttack},
+		{"omission", sanitizedDomain, omissionAttack},
+		{"hyphenation", sanitizedDomain, hyphenationAttack},
+		{"bitsquatting", sanitizedDomain, bitsquattingAttack},
+		{"homograph", sanitizedDomain, homographAttack},
+		{"doppelganger", sanitizedDomain, doppelgangerAttack}}
+}
+
 // helper function to specify permutation attacks to be performed
 func runPermutations(targets []string) {
-	if *outcsv != false || *outjson != false {
+	if len(outputs) > 0 {
 		outputToFile(targets)
 	} else {
 		for _, target := range targets {
 			sanitizedDomain, tld := processInput(target)
-			printReport("addition", additionAttack(sanitizedDomain), tld)
-			printReport("omission", omissionAttack(sanitizedDomain), tld)
-			printReport("homograph", homographAttack(sanitizedDomain), tld)
-			printReport("subdomain", subdomainAttack(sanitizedDomain), tld)
-			printReport("vowel swap", vowelswapAttack(sanitizedDomain), tld)
-			printReport("repetition", repetitionAttack(sanitizedDomain), tld)
-			printReport("hyphenation", hyphenationAttack(sanitizedDomain), tld)
-			printReport("replacement", replacementAttack(sanitizedDomain), tld)
-			printReport("bitsquatting", bitsquattingAttack(sanitizedDomain), tld)
-			printReport("transposition", transpositionAttack(sanitizedDomain), tld)
-			printReport("doppelganger", doppelgangerAttack(sanitizedDomain), tld)
+			printReport("addition", additionAttack(sanitizedDomain), tld, target)
+			printReport("omission", omissionAttack(sanitizedDomain), tld, target)
+			printReport("homograph", homographAttack(sanitizedDomain), tld, target)
+			printReport("subdomain", subdomainAttack(sanitizedDomain), tld, target)
+			printReport("vowel swap", vowelswapAttack(sanitizedDomain), tld, target)
+			printReport("repetition", repetitionAttack(sanitizedDomain), tld, target)
+			printReport("hyphenation", hyphenationAttack(sanitizedDomain), tld, target)
+			printReport("replacement", replacementAttack(sanitizedDomain), tld, target)
+			printReport("bitsquatting", bitsquattingAttack(sanitizedDomain), tld, target)
+			printReport("transposition", transpositionAttack(sanitizedDomain), tld, target)
+			printReport("doppelganger", doppelgangerAttack(sanitizedDomain), tld, target)
 		}
 	}
 }
@@ -801,37 +1071,38 @@ func bitsquattingAttack(domain string) []string {
 	return results
 }
 
+// homoglyphs maps latin characters to their lookalikes
+var homoglyphs = map[rune][]rune{
+	'a': {'à', 'á', 'â', 'ã', 'ä', 'å', 'ɑ', 'а', 'ạ', 'ǎ', 'ă', 'ȧ', 'α', 'ａ'},
+	'b': {'d', 'ʙ', 'Ь', 'ɓ', 'Б', 'ß', 'β', 'ᛒ', '\u1E05', '\u1E03', '\u1D6C'}, // 'lb', 'ib'
+	'c': {'ϲ', 'с', 'ƈ', 'ċ', 'ć', 'ç', 'ｃ'},
+	'd': {'b', 'ԁ', 'ժ', 'ɗ', 'đ'}, // 'cl', 'dl', 'di'
+	'e': {'é', 'ê', 'ë', 'ē', 'ĕ', 'ě', 'ė', 'е', 'ẹ', 'ę', 'є', 'ϵ', 'ҽ'},
+	'f': {'Ϝ', 'ƒ', 'Ғ'},
+	'g': {'q', 'ɢ', 'ɡ', 'Ԍ', 'Ԍ', 'ġ', 'ğ', 'ց', 'ǵ', 'ģ'},
+	'h': {'һ', 'հ', '\u13C2', 'н'}, // 'lh', 'ih'
+	'i': {'1', 'l', '\u13A5', 'í', 'ï', 'ı', 'ɩ', 'ι', 'ꙇ', 'ǐ', 'ĭ'},
+	'j': {'ј', 'ʝ', 'ϳ', 'ɉ'},
+	'k': {'κ', 'κ'}, // 'lk', 'ik', 'lc'
+	'l': {'1', 'i', 'ɫ', 'ł'},
+	'm': {'n', 'ṃ', 'ᴍ', 'м', 'ɱ'}, // 'nn', 'rn', 'rr'
+	'n': {'m', 'r', 'ń'},
+	'o': {'0', 'Ο', 'ο', 'О', 'о', 'Օ', 'ȯ', 'ọ', 'ỏ', 'ơ', 'ó', 'ö', 'ӧ', 'ｏ'},
+	'p': {'ρ', 'р', 'ƿ', 'Ϸ', 'Þ'},
+	'q': {'g', 'զ', 'ԛ', 'գ', 'ʠ'},
+	'r': {'ʀ', 'Г', 'ᴦ', 'ɼ', 'ɽ'},
+	's': {'Ⴝ', '\u13DA', 'ʂ', 'ś', 'ѕ'},
+	't': {'τ', 'т', 'ţ'},
+	'u': {'μ', 'υ', 'Ս', 'ս', 'ц', 'ᴜ', 'ǔ', 'ŭ'},
+	'v': {'ѵ', 'ν', '\u1E7F', '\u1E7D'}, // 'v̇'
+	'w': {'ѡ', 'ա', 'ԝ'},                // 'vv'
+	'x': {'х', 'ҳ', '\u1E8B'},
+	'y': {'ʏ', 'γ', 'у', 'Ү', 'ý'},
+	'z': {'ʐ', 'ż', 'ź', 'ʐ', 'ᴢ'},
+}
+
 // performs a homograph permutation attack
 func homographAttack(domain string) []string {
-	// set local variables
-	glyphs := map[rune][]rune{
-		'a': {'à', 'á', 'â', 'ã', 'ä', 'å', 'ɑ', 'а', 'ạ', 'ǎ', 'ă', 'ȧ', 'α', 'ａ'},
-		'b': {'d', 'ʙ', 'Ь', 'ɓ', 'Б', 'ß', 'β', 'ᛒ', '\u1E05', '\u1E03', '\u1D6C'}, // 'lb', 'ib'
-		'c': {'ϲ', 'с', 'ƈ', 'ċ', 'ć', 'ç', 'ｃ'},
-		'd': {'b', 'ԁ', 'ժ', 'ɗ', 'đ'}, // 'cl', 'dl', 'di'
-		'e': {'é', 'ê', 'ë', 'ē', 'ĕ', 'ě', 'ė', 'е', 'ẹ', 'ę', 'є', 'ϵ', 'ҽ'},
-		'f': {'Ϝ', 'ƒ', 'Ғ'},
-		'g': {'q', 'ɢ', 'ɡ', 'Ԍ', 'Ԍ', 'ġ', 'ğ', 'ց', 'ǵ', 'ģ'},
-		'h': {'һ', 'հ', '\u13C2', 'н'}, // 'lh', 'ih'
-		'i': {'1', 'l', '\u13A5', 'í', 'ï', 'ı', 'ɩ', 'ι', 'ꙇ', 'ǐ', 'ĭ'},
-		'j': {'ј', 'ʝ', 'ϳ', 'ɉ'},
-		'k': {'κ', 'κ'}, // 'lk', 'ik', 'lc'
-		'l': {'1', 'i', 'ɫ', 'ł'},
-		'm': {'n', 'ṃ', 'ᴍ', 'м', 'ɱ'}, // 'nn', 'rn', 'rr'
-		'n': {'m', 'r', 'ń'},
-		'o': {'0', 'Ο', 'ο', 'О', 'о', 'Օ', 'ȯ', 'ọ', 'ỏ', 'ơ', 'ó', 'ö', 'ӧ', 'ｏ'},
-		'p': {'ρ', 'р', 'ƿ', 'Ϸ', 'Þ'},
-		'q': {'g', 'զ', 'ԛ', 'գ', 'ʠ'},
-		'r': {'ʀ', 'Г', 'ᴦ', 'ɼ', 'ɽ'},
-		's': {'Ⴝ', '\u13DA', 'ʂ', 'ś', 'ѕ'},
-		't': {'τ', 'т', 'ţ'},
-		'u': {'μ', 'υ', 'Ս', 'ս', 'ц', 'ᴜ', 'ǔ', 'ŭ'},
-		'v': {'ѵ', 'ν', '\u1E7F', '\u1E7D'}, // 'v̇'
-		'w': {'ѡ', 'ա', 'ԝ'},                // 'vv'
-		'x': {'х', 'ҳ', '\u1E8B'},
-		'y': {'ʏ', 'γ', 'у', 'Ү', 'ý'},
-		'z': {'ʐ', 'ż', 'ź', 'ʐ', 'ᴢ'},
-	}
 	doneCount := make(map[rune]bool)
 	results := []string{}
 	runes := []rune(domain)
@@ -839,7 +1110,7 @@ func homographAttack(domain string) []string {
 
 	for i, char := range runes {
 		// perform attack against single character
-		for _, glyph := range glyphs[char] {
+		for _, glyph := range homoglyphs[char] {
 			results = append(results, fmt.Sprintf("%s%c%s", string(runes[:i]), glyph, string(runes[i+1:])))
 		}
 		// determine if character is a duplicate
@@ -847,7 +1118,7 @@ func homographAttack(domain string) []string {
 		// against all characters at the same time
 		if count[char] > 1 && doneCount[char] != true {
 			doneCount[char] = true
-			for _, glyph := range glyphs[char] {
+			for _, glyph := range homoglyphs[char] {
 				result := strings.Replace(domain, string(char), string(glyph), -1)
 				results = append(results, result)
 			}
@@ -920,15 +1191,28 @@ func Unzip(src string, dest string) ([]string, error) {
 // main program entry point
 func main() {
 	updateRelease()
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		runReport(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "ct" {
+		runCT(os.Args[2:])
+		return
+	}
+	setup()
+
 	// check if geolocation database is zipped, if so unzip
-	if _, err := os.Stat("data/GeoLite2-City.zip"); !os.IsNotExist(err) {
-		_, err := Unzip("data/GeoLite2-City.zip", "data")
+	geoDB := geoDBPath()
+	geoZip := strings.TrimSuffix(geoDB, filepath.Ext(geoDB)) + ".zip"
+	if _, err := os.Stat(geoZip); !os.IsNotExist(err) {
+		_, err := Unzip(geoZip, filepath.Dir(geoDB))
 		if err != nil {
-			log.Fatal(err)
+			y.Fprintf(os.Stderr, "[!] error unzipping %s: %v\n", geoZip, err)
+		} else {
+			os.Remove(geoZip)
 		}
-		os.Remove("data/GeoLite2-City.zip")
 	}
-	setup()
+	defer closeGeoDBs()
 
 	if *domain != "" && *list == "" {
 		sanitizedDomain, tld := processInput(*domain)
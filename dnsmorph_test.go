@@ -1,6 +1,9 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -71,12 +74,70 @@ func TestAttackResults(t *testing.T) {
 
 func TestWhoisLookup(t *testing.T) {
 
-	result := whoisLookup("google.com")
-	if result[0] != "1997-09-15T04:00:00Z" {
-		t.Errorf("expected 1997-09-15T04:00:00Z, got %s", result[0])
+	result, err := whoisLookup("google.com")
+	if err != nil {
+		t.Fatal("whois lookup failed:", err)
+	}
+	if result.Created != "1997-09-15T04:00:00Z" {
+		t.Errorf("expected 1997-09-15T04:00:00Z, got %s", result.Created)
 	}
 
-	if result[1] != "2019-09-09T15:39:04Z" {
-		t.Errorf("expected 2019-09-09T15:39:04Z, got %s", result[1])
+	if result.Updated != "2019-09-09T15:39:04Z" {
+		t.Errorf("expected 2019-09-09T15:39:04Z, got %s", result.Updated)
+	}
+}
+
+func TestGeoLookupMissingDatabase(t *testing.T) {
+	previous := *geoipDB
+	*geoipDB = filepath.Join(t.TempDir(), "missing.mmdb")
+	geoReaderOnce = sync.Once{}
+	defer func() {
+		*geoipDB = previous
+		geoReaderOnce = sync.Once{}
+	}()
+	if result := geoLookup("192.0.2.1"); result != (Geolocation{}) {
+		t.Errorf("expected empty geolocation, got %v", result)
+	}
+}
+
+func TestGeoDBPath(t *testing.T) {
+	previous := *geoipDB
+	defer func() { *geoipDB = previous }()
+	*geoipDB = ""
+	os.Setenv("DNSMORPH_GEOIP_DB", "/tmp/env.mmdb")
+	defer os.Unsetenv("DNSMORPH_GEOIP_DB")
+	if path := geoDBPath(); path != "/tmp/env.mmdb" {
+		t.Errorf("expected environment path, got '%s'", path)
+	}
+	*geoipDB = "/tmp/flag.mmdb"
+	if path := geoDBPath(); path != "/tmp/flag.mmdb" {
+		t.Errorf("expected option path, got '%s'", path)
+	}
+}
+
+func TestSetGeolocation(t *testing.T) {
+	r := new(Record)
+	r.setGeolocation(Geolocation{CountryCode: "DE", CountryName: "Germany", Subdivision: "Hesse", City: "Frankfurt am Main",
+		Latitude: 50.1188, Longitude: 8.6843, AccuracyRadius: 20, ASN: 64496, ASOrg: "Example Hosting GmbH"})
+	if r.Geolocation != "DE Frankfurt am Main" {
+		t.Errorf("expected 'DE Frankfurt am Main', got '%s'", r.Geolocation)
+	}
+	data := r.csvData()
+	expected := []string{"DE", "Germany", "Hesse", "Frankfurt am Main", "50.1188", "8.6843", "20", "AS64496", "Example Hosting GmbH"}
+	start := csvColumn("country_code")
+	for i, value := range data[start : start+len(expected)] {
+		if value != expected[i] {
+			t.Errorf("expected csv field '%s', got '%s'", expected[i], value)
+		}
+	}
+}
+
+func TestCSVColumns(t *testing.T) {
+	r := &Record{HTTP: &HTTPProbe{StatusCode: 200}}
+	if len(csvColumns) != len(r.csvData()) {
+		t.Errorf("expected %d csv columns, got %d", len(r.csvData()), len(csvColumns))
+	}
+	if column := csvColumn("target"); column != 15 {
+		t.Errorf("expected the target column at 15, got %d", column)
 	}
 }
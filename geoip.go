@@ -0,0 +1,192 @@
+package main
+
+import (
+	"github.com/oschwald/maxminddb-golang"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	geoReader     *maxminddb.Reader
+	geoReaderOnce sync.Once
+	asnReader     *maxminddb.Reader
+	asnReaderOnce sync.Once
+)
+
+// GeoIPRecord struct
+type GeoIPRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		IsoCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	Location struct {
+		AccuracyRadius uint16  `maxminddb:"accuracy_radius"`
+		Latitude       float64 `maxminddb:"latitude"`
+		Longitude      float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// ASNRecord struct
+type ASNRecord struct {
+	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+// Geolocation struct
+type Geolocation struct {
+	CountryCode    string
+	CountryName    string
+	Subdivision    string
+	City           string
+	Latitude       float64
+	Longitude      float64
+	AccuracyRadius uint16
+	ASN            uint
+	ASOrg          string
+}
+
+// returns a database path, set by option, environment variable or default
+func mmdbPath(option, env, filename string) string {
+	if option != "" {
+		return option
+	}
+	if path := os.Getenv(env); path != "" {
+		return path
+	}
+	return filepath.Join("data", filename)
+}
+
+// returns the geolocation database path
+func geoDBPath() string {
+	return mmdbPath(*geoipDB, "DNSMORPH_GEOIP_DB", "GeoLite2-City.mmdb")
+}
+
+// returns the ASN database path
+func asnDBPath() string {
+	return mmdbPath(*asnDB, "DNSMORPH_ASN_DB", "GeoLite2-ASN.mmdb")
+}
+
+// opens the geolocation database once per run, warns and returns nil when unavailable
+func openGeoDB() *maxminddb.Reader {
+	geoReaderOnce.Do(func() {
+		db, err := maxminddb.Open(geoDBPath())
+		if err != nil {
+			y.Fprintf(os.Stderr, "[!] geolocation disabled: %v\n", err)
+			return
+		}
+		geoReader = db
+	})
+	return geoReader
+}
+
+// opens the optional ASN database once per run, only warns when it was configured explicitly
+func openASNDB() *maxminddb.Reader {
+	asnReaderOnce.Do(func() {
+		db, err := maxminddb.Open(asnDBPath())
+		if err != nil {
+			if *asnDB != "" || os.Getenv("DNSMORPH_ASN_DB") != "" {
+				y.Fprintf(os.Stderr, "[!] ASN lookups disabled: %v\n", err)
+			}
+			return
+		}
+		asnReader = db
+	})
+	return asnReader
+}
+
+// closes the databases opened during the run
+func closeGeoDBs() {
+	if geoReader != nil {
+		geoReader.Close()
+	}
+	if asnReader != nil {
+		asnReader.Close()
+	}
+}
+
+// performs a geolocation and ASN lookup on input IP
+func geoLookup(inputIP string) Geolocation {
+	var geo Geolocation
+	ip := net.ParseIP(inputIP)
+	if ip == nil {
+		return geo
+	}
+	if db := openGeoDB(); db != nil {
+		var record GeoIPRecord
+		if err := db.Lookup(ip, &record); err == nil {
+			geo.CountryCode = record.Country.IsoCode
+			geo.CountryName = record.Country.Names["en"]
+			if len(record.Subdivisions) > 0 {
+				geo.Subdivision = record.Subdivisions[0].Names["en"]
+			}
+			geo.City = record.City.Names["en"]
+			geo.Latitude = record.Location.Latitude
+			geo.Longitude = record.Location.Longitude
+			geo.AccuracyRadius = record.Location.AccuracyRadius
+		}
+	}
+	geo.ASN, geo.ASOrg = asnLookup(inputIP)
+	return geo
+}
+
+// performs an ASN lookup on input IP, returns the AS number and organisation
+func asnLookup(inputIP string) (uint, string) {
+	ip := net.ParseIP(inputIP)
+	db := openASNDB()
+	if ip == nil || db == nil {
+		return 0, ""
+	}
+	var record ASNRecord
+	if err := db.Lookup(ip, &record); err != nil {
+		return 0, ""
+	}
+	return record.AutonomousSystemNumber, record.AutonomousSystemOrganization
+}
+
+// sets the geolocation fields of a Record, Geolocation keeps the country + city summary
+func (r *Record) setGeolocation(geo Geolocation) {
+	r.CountryCode = geo.CountryCode
+	r.CountryName = geo.CountryName
+	r.Subdivision = geo.Subdivision
+	r.City = geo.City
+	r.Latitude = geo.Latitude
+	r.Longitude = geo.Longitude
+	r.AccuracyRadius = geo.AccuracyRadius
+	r.ASN = geo.ASN
+	r.ASOrg = geo.ASOrg
+	r.Geolocation = strings.TrimSpace(geo.CountryCode + " " + geo.City)
+}
+
+// formats a coordinate for csv output, empty when the record is not geolocated
+func (r *Record) coordinate(value float64) string {
+	if r.CountryCode == "" && r.City == "" && value == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(value, 'f', 4, 64)
+}
+
+// formats the accuracy radius for csv output
+func (r *Record) accuracyRadius() string {
+	if r.AccuracyRadius == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(r.AccuracyRadius))
+}
+
+// formats the autonomous system number for csv output
+func (r *Record) asn() string {
+	if r.ASN == 0 {
+		return ""
+	}
+	return "AS" + strconv.FormatUint(uint64(r.ASN), 10)
+}
@@ -1,6 +1,6 @@
 module github.com/netevert/dnsmorph
 
-go 1.15
+go 1.16
 
 require (
 	github.com/cavaliercoder/grab v2.0.0+incompatible
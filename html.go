@@ -0,0 +1,164 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed templates/report.html
+var htmlReportTemplate string
+
+// width in pixels of the longest bar of report charts
+const chartWidth = 360
+
+// htmlOutput writes the result set as a self-contained html report
+type htmlOutput struct {
+	w       io.Writer
+	records []Record
+}
+
+// htmlReport holds the data rendered by the html report template
+type htmlReport struct {
+	Targets    []string
+	Generated  string
+	Version    string
+	Total      int
+	Registered int
+	Live       int
+	Techniques htmlChart
+	Statuses   htmlChart
+	Rows       []htmlRow
+}
+
+// htmlChart is a horizontal bar chart rendered as inline svg
+type htmlChart struct {
+	Title  string
+	Width  int
+	Height int
+	Bars   []htmlBar
+}
+
+// htmlBar is a bar of a chart
+type htmlBar struct {
+	Label string
+	Count int
+	Y     int
+	Width int
+}
+
+// htmlRow is a table row of the html report
+type htmlRow struct {
+	Record
+	Live         bool
+	Registration string
+	Location     string
+	Content      string
+}
+
+func newHTMLOutput(w io.Writer) outputWriter {
+	return &htmlOutput{w: w}
+}
+
+func (o *htmlOutput) write(r *Record) error {
+	o.records = append(o.records, *r)
+	return nil
+}
+
+// reports whether a candidate answers http probes or, when not probed, resolves to an address
+func (r *Record) live() bool {
+	if r.HTTP != nil || r.HTTPS != nil {
+		return r.HTTP != nil && r.HTTP.StatusCode > 0 || r.HTTPS != nil && r.HTTPS.StatusCode > 0
+	}
+	return r.A != "" || len(r.IPs) > 0
+}
+
+// returns a bar chart of counts, largest first
+func newHTMLChart(title string, counts map[string]int) htmlChart {
+	// labels take the left 180 pixels, counts the right 50
+	chart := htmlChart{Title: title, Width: chartWidth + 230}
+	max := 0
+	for label, count := range counts {
+		chart.Bars = append(chart.Bars, htmlBar{Label: label, Count: count})
+		if count > max {
+			max = count
+		}
+	}
+	sort.Slice(chart.Bars, func(i, j int) bool {
+		if chart.Bars[i].Count != chart.Bars[j].Count {
+			return chart.Bars[i].Count > chart.Bars[j].Count
+		}
+		return chart.Bars[i].Label < chart.Bars[j].Label
+	})
+	for i := range chart.Bars {
+		chart.Bars[i].Y = i * 24
+		chart.Bars[i].Width = chart.Bars[i].Count * chartWidth / max
+		if chart.Bars[i].Width == 0 {
+			chart.Bars[i].Width = 1
+		}
+	}
+	chart.Height = len(chart.Bars) * 24
+	return chart
+}
+
+// builds the report data from the result set
+func newHTMLReport(records []Record, now time.Time) htmlReport {
+	report := htmlReport{Generated: now.Format("2006-01-02 15:04 MST"), Version: version, Total: len(records)}
+	techniques, statuses := make(map[string]int), make(map[string]int)
+	for _, r := range records {
+		report.Targets = appendUnique(report.Targets, r.Target)
+		techniques[r.Technique]++
+		status := r.Status
+		if status == "" {
+			status = "not resolved"
+		}
+		statuses[status]++
+		row := htmlRow{Record: r, Live: r.live(), Registration: r.registrationLabel()}
+		if r.Registration == registered {
+			report.Registered++
+		}
+		if row.Live {
+			report.Live++
+		}
+		var location []string
+		for _, part := range []string{r.City, r.CountryName} {
+			if part != "" {
+				location = append(location, part)
+			}
+		}
+		row.Location = strings.Join(location, ", ")
+		if row.Location == "" {
+			row.Location = r.Geolocation
+		}
+		if r.RedirectsToTarget {
+			row.Content = "redirects to target"
+		} else if r.HTTP != nil || r.HTTPS != nil {
+			// contentLabel colors the label for terminals
+			row.Content = fmt.Sprintf("%d%% similar", r.Similarity)
+			if r.LoginForm {
+				row.Content += " login form"
+			} else if r.PasswordField {
+				row.Content += " password field"
+			}
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	report.Techniques = newHTMLChart("Permutations per technique", techniques)
+	report.Statuses = newHTMLChart("Permutations per DNS status", statuses)
+	return report
+}
+
+func (o *htmlOutput) close() error {
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"join": strings.Join,
+		"add":  func(a, b int) int { return a + b },
+	}).Parse(htmlReportTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(o.w, newHTMLReport(o.records, time.Now()))
+}
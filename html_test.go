@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTMLChart(t *testing.T) {
+	chart := newHTMLChart("techniques", map[string]int{"addition": 2, "homograph": 8, "omission": 2})
+	if len(chart.Bars) != 3 || chart.Height != 72 {
+		t.Fatalf("unexpected chart %+v", chart)
+	}
+	if bar := chart.Bars[0]; bar.Label != "homograph" || bar.Width != chartWidth || bar.Y != 0 {
+		t.Errorf("expected the largest bar first, got %+v", bar)
+	}
+	if bar := chart.Bars[1]; bar.Label != "addition" || bar.Width != chartWidth/4 || bar.Y != 24 {
+		t.Errorf("expected ties ordered by label, got %+v", bar)
+	}
+}
+
+func TestHTMLReport(t *testing.T) {
+	records := []Record{
+		{Target: "example.com", Technique: "addition", Domain: "examplea.com", Status: statusNXDomain, Registration: unregistered},
+		{Target: "example.com", Technique: "omission", Domain: "exmple.com", Status: statusNoError, Registration: registered, A: "192.0.2.1"},
+		{Target: "example.com", Technique: "omission", Domain: "exampe.com", Status: statusNoError, Registration: registered,
+			A: "192.0.2.2", HTTPS: &HTTPProbe{Error: "timeout"}},
+		{Target: "example.com", Technique: "homograph", Domain: "examp1e.com", Registrar: "<script>alert(1)</script>"},
+	}
+	report := newHTMLReport(records, time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC))
+	if report.Total != 4 || report.Registered != 2 || report.Live != 1 {
+		t.Errorf("unexpected counts %d, %d registered, %d live", report.Total, report.Registered, report.Live)
+	}
+	if bar := report.Statuses.Bars[0]; bar.Label != statusNoError || bar.Count != 2 {
+		t.Errorf("unexpected status bar %+v", bar)
+	}
+	if bar := report.Statuses.Bars[2]; bar.Label != "not resolved" {
+		t.Errorf("expected unresolved permutations counted, got %+v", bar)
+	}
+
+	var buf bytes.Buffer
+	writer := newHTMLOutput(&buf)
+	for i := range records {
+		writer.write(&records[i])
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, expected := range []string{"<title>dnsmorph report - example.com</title>", `<tr class="registered live">`,
+		`<tr class="registered">`, "&lt;script&gt;alert(1)&lt;/script&gt;", "<svg"} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("expected %q in the report", expected)
+		}
+	}
+	// the report must not fetch anything
+	for _, reference := range []string{"src=", "href=", "@import", "url("} {
+		if strings.Contains(page, reference) {
+			t.Errorf("unexpected external reference %q in the report", reference)
+		}
+	}
+}
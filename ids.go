@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"golang.org/x/net/idna"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strings"
+)
+
+// number of signature id slots, each registered candidate takes two consecutive ids so that
+// the default range 1000000-1999999 stays within the ids reserved for local rules
+const sidSlots = 500000
+
+// ids reserved for the Emerging Threats rule sets
+const (
+	etFirstSID = 2000000
+	etLastSID  = 2999999
+)
+
+// idsOutput writes DNS query and TLS SNI rules for registered candidates
+type idsOutput struct {
+	w       io.Writer
+	records map[string]*Record
+	rules   func(r *Record, domain string, sid int) string
+}
+
+// checks the signature id range of rule outputs
+func validateRuleOptions() error {
+	first, last := *sidBase, *sidBase+2*sidSlots-1
+	if first < 1 || first <= etLastSID && last >= etFirstSID {
+		return fmt.Errorf("invalid sid base %d, rules take ids %d-%d which must be positive and outside the Emerging Threats range %d-%d",
+			*sidBase, first, last, etFirstSID, etLastSID)
+	}
+	return nil
+}
+
+// returns the signature id slots of domains, derived from their hash so that rules keep their ids
+// across runs; colliding domains take the next free slot in lexical order
+func sidSlotsOf(domains []string) map[string]uint32 {
+	sort.Strings(domains)
+	slots := make(map[string]uint32, len(domains))
+	taken := make(map[uint32]bool, len(domains))
+	for _, domain := range domains {
+		hash := fnv.New32a()
+		hash.Write([]byte(domain))
+		slot := hash.Sum32() % sidSlots
+		for taken[slot] {
+			slot = (slot + 1) % sidSlots
+		}
+		taken[slot] = true
+		slots[domain] = slot
+	}
+	return slots
+}
+
+func (o *idsOutput) write(r *Record) error {
+	if r.Registration != registered || r.Owned || r.Defensive {
+		return nil
+	}
+	// rules match the punycode form seen on the wire
+	domain, err := idna.Lookup.ToASCII(r.Domain)
+	if err != nil {
+		return nil
+	}
+	domain = strings.ToLower(domain)
+	if o.records[domain] == nil {
+		record := *r
+		o.records[domain] = &record
+	}
+	return nil
+}
+
+// writes the rules once every candidate is known, ids being assigned independently of the lookup order
+func (o *idsOutput) close() error {
+	domains := make([]string, 0, len(o.records))
+	for domain := range o.records {
+		domains = append(domains, domain)
+	}
+	// rules are written in lexical order of their domains
+	slots := sidSlotsOf(domains)
+	for _, domain := range domains {
+		if _, err := io.WriteString(o.w, o.rules(o.records[domain], domain, *sidBase+int(slots[domain])*2)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the rule message of a candidate, naming the protected brand and the technique
+func ruleMessage(r *Record, rule, domain string) string {
+	return fmt.Sprintf("DNSMORPH %s lookalike of %s %s %s", r.Technique, r.Target, rule, domain)
+}
+
+// encodes domain as the length prefixed labels of a dns query
+func wireName(domain string) string {
+	var b strings.Builder
+	for _, label := range strings.Split(domain, ".") {
+		fmt.Fprintf(&b, "|%02x|%s", len(label), label)
+	}
+	return b.String() + "|00|"
+}
+
+func newSuricataOutput(w io.Writer) outputWriter {
+	return &idsOutput{w: w, records: make(map[string]*Record),
+		rules: func(r *Record, domain string, sid int) string {
+			// dotprefix and endswith match the domain and its subdomains
+			return fmt.Sprintf("alert dns any any -> any any (msg:\"%s\"; dns.query; dotprefix; content:\".%s\"; nocase; endswith; classtype:bad-unknown; sid:%d; rev:1;)\n",
+				ruleMessage(r, "DNS query", domain), domain, sid) +
+				fmt.Sprintf("alert tls any any -> any any (msg:\"%s\"; tls.sni; dotprefix; content:\".%s\"; nocase; endswith; classtype:bad-unknown; sid:%d; rev:1;)\n",
+					ruleMessage(r, "TLS SNI", domain), domain, sid+1)
+		}}
+}
+
+func newSnortOutput(w io.Writer) outputWriter {
+	return &idsOutput{w: w, records: make(map[string]*Record),
+		rules: func(r *Record, domain string, sid int) string {
+			return fmt.Sprintf("alert udp $HOME_NET any -> any 53 (msg:\"%s\"; content:\"%s\"; nocase; fast_pattern; classtype:bad-unknown; sid:%d; rev:1;)\n",
+				ruleMessage(r, "DNS query", domain), wireName(domain), sid) +
+				fmt.Sprintf("alert tcp $HOME_NET any -> $EXTERNAL_NET 443 (msg:\"%s\"; flow:to_server,established; content:\"|16 03|\"; depth:2; content:\"|01|\"; distance:3; within:1; content:\"%s\"; nocase; classtype:bad-unknown; sid:%d; rev:1;)\n",
+					ruleMessage(r, "TLS SNI", domain), domain, sid+1)
+		}}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"testing"
+)
+
+// renders registered candidates as rules in format
+func renderRules(t *testing.T, format string, records []Record) []string {
+	var buf bytes.Buffer
+	writer := outputFormats[format](&buf)
+	for i := range records {
+		if err := writer.write(&records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+func TestSuricataRules(t *testing.T) {
+	records := []Record{
+		{Target: "example.com", Technique: "homograph", Domain: "еxample.com", Registration: registered},
+		{Target: "example.com", Technique: "omission", Domain: "exmple.com", Registration: unregistered},
+		{Target: "example.com", Technique: "addition", Domain: "examplea.com", Registration: registered, Owned: true},
+	}
+	rules := renderRules(t, "suricata", records)
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d:\n%s", len(rules), strings.Join(rules, "\n"))
+	}
+	for _, expected := range []string{
+		`alert dns any any -> any any (msg:"DNSMORPH homograph lookalike of example.com DNS query xn--xample-2of.com"; dns.query; dotprefix; content:".xn--xample-2of.com"; nocase; endswith;`,
+		`alert tls any any -> any any (msg:"DNSMORPH homograph lookalike of example.com TLS SNI xn--xample-2of.com"; tls.sni; dotprefix; content:".xn--xample-2of.com"; nocase; endswith;`,
+	} {
+		if !strings.HasPrefix(rules[0], expected) && !strings.HasPrefix(rules[1], expected) {
+			t.Errorf("missing rule %s", expected)
+		}
+	}
+	// signature ids are stable across runs and lookup orders, and consecutive for a domain
+	again := renderRules(t, "suricata", []Record{records[2], records[1], records[0]})
+	if rules[0] != again[0] || rules[1] != again[1] {
+		t.Error("expected identical rules across runs")
+	}
+	if dns, tls := ruleSID(rules[0]), ruleSID(rules[1]); tls != dns+1 || dns < *sidBase || dns >= *sidBase+2*sidSlots {
+		t.Errorf("unexpected signature ids %d and %d", dns, tls)
+	}
+}
+
+func TestSnortRules(t *testing.T) {
+	rules := renderRules(t, "snort", []Record{{Target: "example.com", Technique: "transposition", Domain: "exampel.com", Registration: registered}})
+	if len(rules) != 2 || !strings.Contains(rules[0], `content:"|07|exampel|03|com|00|"`) || !strings.Contains(rules[1], `content:"exampel.com"`) {
+		t.Errorf("unexpected rules:\n%s", strings.Join(rules, "\n"))
+	}
+}
+
+func TestRuleSIDs(t *testing.T) {
+	previous := *sidBase
+	*sidBase = 5000000
+	defer func() { *sidBase = previous }()
+	rules := renderRules(t, "suricata", []Record{{Target: "example.com", Technique: "addition", Domain: "examplea.com", Registration: registered}})
+	if sid := ruleSID(rules[0]); sid < 5000000 || sid >= 5000000+2*sidSlots || sid%2 != 0 {
+		t.Errorf("expected an even sid from the configured base, got %d", sid)
+	}
+
+	// colliding domains take the next free slot in lexical order, whatever their arrival order
+	hash := fnv.New32a()
+	hash.Write([]byte("exampel.com"))
+	collision := ""
+	for i := 0; collision == ""; i++ {
+		candidate := fmt.Sprintf("example%d.com", i)
+		other := fnv.New32a()
+		other.Write([]byte(candidate))
+		if other.Sum32()%sidSlots == hash.Sum32()%sidSlots {
+			collision = candidate
+		}
+	}
+	for _, domains := range [][]string{{"exampel.com", collision}, {collision, "exampel.com"}} {
+		slots := sidSlotsOf(domains)
+		if slots["exampel.com"] != hash.Sum32()%sidSlots || slots[collision] != (hash.Sum32()+1)%sidSlots {
+			t.Errorf("%v: unexpected slots %v", domains, slots)
+		}
+	}
+}
+
+func TestValidateRuleOptions(t *testing.T) {
+	previous := *sidBase
+	defer func() { *sidBase = previous }()
+	for base, valid := range map[int]bool{1000000: true, 1000001: false, 1500000: false, 2500000: false, 3000000: true, 0: false} {
+		*sidBase = base
+		if err := validateRuleOptions(); (err == nil) != valid {
+			t.Errorf("sid base %d: expected valid %v, got %v", base, valid, err)
+		}
+	}
+}
+
+// extracts the signature id of a rule
+func ruleSID(rule string) int {
+	var sid int
+	if i := strings.Index(rule, "sid:"); i >= 0 {
+		fmt.Sscanf(rule[i+4:], "%d", &sid)
+	}
+	return sid
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/publicsuffix"
+	"net"
+	"strings"
+	"sync"
+)
+
+// infrastructure match levels, from strongest to weakest
+const (
+	matchIP  = "ip"
+	matchPTR = "ptr"
+	matchNet = "/24"
+	matchASN = "asn"
+)
+
+// infrastructure holds the hosting footprint of a protected domain
+type infrastructure struct {
+	once sync.Once
+	ips  map[string]bool
+	nets map[string]bool
+	asns map[uint]bool
+}
+
+var origins sync.Map
+
+// returns the /24 network of an IPv4 address
+func network24(inputIP string) string {
+	ip := net.ParseIP(inputIP).To4()
+	if ip == nil {
+		return ""
+	}
+	return ip.Mask(net.CIDRMask(24, 32)).String() + "/24"
+}
+
+// returns the lowercased registrable domain of a host name, or the name itself when it has none
+func registrableDomain(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if registrable, err := publicsuffix.EffectiveTLDPlusOne(name); err == nil {
+		return registrable
+	}
+	return name
+}
+
+// performs a PTR lookup on input IP, returns the first host name
+func ptrLookup(inputIP string) string {
+	ip := net.ParseIP(inputIP).To4()
+	if ip == nil {
+		return ""
+	}
+	reverse := fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip[3], ip[2], ip[1], ip[0])
+	for _, rr := range dnsQuery(reverse, dnsmessage.TypePTR).Answers {
+		if ptr, ok := rr.Body.(*dnsmessage.PTRResource); ok {
+			return strings.ToLower(strings.TrimSuffix(ptr.PTR.String(), "."))
+		}
+	}
+	return ""
+}
+
+// resolves the protected domain once per run and returns its infrastructure
+func originInfrastructure(target string) *infrastructure {
+	value, _ := origins.LoadOrStore(target, &infrastructure{})
+	infra := value.(*infrastructure)
+	infra.once.Do(func() {
+		infra.ips = make(map[string]bool)
+		infra.nets = make(map[string]bool)
+		infra.asns = make(map[uint]bool)
+		for _, name := range []string{target, "www." + target} {
+			for _, ip := range aLookup(name).addrs() {
+				infra.ips[ip] = true
+				infra.nets[network24(ip)] = true
+				if asn, _ := asnLookup(ip); asn != 0 {
+					infra.asns[asn] = true
+				}
+			}
+		}
+	})
+	return infra
+}
+
+// compares candidate addresses and reverse dns with the protected domain, returns the
+// strongest match level and whether the candidate is probably a defensive registration;
+// only a reverse dns name within the protected registrable domain is conclusive, as shared
+// hosting and CDN addresses, networks and ASNs host attackers alongside the brand
+func (infra *infrastructure) match(target, ptr string, addrs []string) (string, bool) {
+	if ptr != "" && registrableDomain(ptr) == registrableDomain(target) {
+		return matchPTR, true
+	}
+	level := ""
+	for _, ip := range addrs {
+		if infra.ips[ip] {
+			return matchIP, false
+		}
+		if infra.nets[network24(ip)] {
+			level = matchNet
+		} else if level == "" {
+			if asn, _ := asnLookup(ip); asn != 0 && infra.asns[asn] {
+				level = matchASN
+			}
+		}
+	}
+	return level, false
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"golang.org/x/net/dns/dnsmessage"
+	"testing"
+)
+
+func ptrReply(host string) func(q dnsmessage.Question) dnsmessage.Message {
+	return func(q dnsmessage.Question) dnsmessage.Message {
+		return dnsmessage.Message{Answers: []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(host)},
+		}}}
+	}
+}
+
+func TestPTRLookup(t *testing.T) {
+	useResolver(t, startDNSStub(t, stubZone{
+		"10.2.0.192.in-addr.arpa.": ptrReply("Host10.Brand.Test."),
+	}))
+	if ptr := ptrLookup("192.0.2.10"); ptr != "host10.brand.test" {
+		t.Errorf("expected 'host10.brand.test', got '%s'", ptr)
+	}
+	if ptr := ptrLookup("192.0.2.11"); ptr != "" {
+		t.Errorf("expected empty ptr, got '%s'", ptr)
+	}
+}
+
+func TestInfrastructureMatch(t *testing.T) {
+	useResolver(t, startDNSStub(t, stubZone{
+		"brand.test.":     aReply("192.0.2.1"),
+		"www.brand.test.": aReply("192.0.2.2"),
+	}))
+	infra := originInfrastructure("brand.test")
+	for _, test := range []struct {
+		ptr       string
+		addrs     []string
+		level     string
+		defensive bool
+	}{
+		{"", []string{"198.51.100.1", "192.0.2.2"}, matchIP, false},
+		{"mail.brand.test", []string{"198.51.100.1"}, matchPTR, true},
+		{"mail.brand.test", []string{"192.0.2.1"}, matchPTR, true},
+		{"", []string{"192.0.2.200"}, matchNet, false},
+		{"parked.example", []string{"198.51.100.1"}, "", false},
+		{"edge.cdn.example", []string{"192.0.2.1"}, matchIP, false},
+	} {
+		level, defensive := infra.match("brand.test", test.ptr, test.addrs)
+		if level != test.level || defensive != test.defensive {
+			t.Errorf("%v: expected %s/%v, got %s/%v", test.addrs, test.level, test.defensive, level, defensive)
+		}
+	}
+}
+
+func TestInfrastructureMatchSubdomainTarget(t *testing.T) {
+	useResolver(t, startDNSStub(t, stubZone{}))
+	infra := originInfrastructure("shop.brand.test")
+	if level, defensive := infra.match("shop.brand.test", "host1.brand.test", nil); level != matchPTR || !defensive {
+		t.Errorf("expected a ptr match within the registrable domain, got %s/%v", level, defensive)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/idna"
+	"net"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	smtpPort    = "25"
+	smtpTimeout = 10 * time.Second
+)
+
+// returns the mail exchangers of domain in preference order, falling back to the
+// domain itself when it has an address but no MX records (RFC 5321 implicit MX)
+func mxLookup(domain string) ([]string, bool) {
+	result := dnsQuery(domain, dnsmessage.TypeMX)
+	var records []*dnsmessage.MXResource
+	for _, rr := range result.Answers {
+		if mx, ok := rr.Body.(*dnsmessage.MXResource); ok {
+			records = append(records, mx)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Pref < records[j].Pref })
+	var hosts []string
+	for _, mx := range records {
+		host := strings.ToLower(strings.TrimSuffix(mx.MX.String(), "."))
+		// a null MX declares that the domain does not accept mail (RFC 7505)
+		if host == "" {
+			return nil, false
+		}
+		hosts = append(hosts, host)
+	}
+	if len(hosts) == 0 && result.Status == statusNoData && aLookup(domain).ip() != "" {
+		return []string{domain}, true
+	}
+	return hosts, false
+}
+
+// returns the first TXT record of domain starting with prefix
+func txtLookup(domain, prefix string) string {
+	for _, rr := range dnsQuery(domain, dnsmessage.TypeTXT).Answers {
+		if txt, ok := rr.Body.(*dnsmessage.TXTResource); ok {
+			// long records are split in strings that are concatenated without separator
+			record := strings.Join(txt.TXT, "")
+			if strings.HasPrefix(strings.ToLower(record), strings.ToLower(prefix)) {
+				return record
+			}
+		}
+	}
+	return ""
+}
+
+// returns the SPF record of domain and its catch-all mechanism, e.g. -all
+func spfLookup(domain string) (string, string) {
+	record := txtLookup(domain, "v=spf1")
+	terms := strings.Fields(strings.ToLower(record))
+	if len(terms) == 0 || terms[0] != "v=spf1" {
+		return "", ""
+	}
+	all := ""
+	for _, term := range terms[1:] {
+		switch term {
+		case "all", "+all":
+			all = "+all"
+		case "-all", "~all", "?all":
+			all = term
+		}
+	}
+	return record, all
+}
+
+// returns the DMARC record of domain and its policy
+func dmarcLookup(domain string) (string, string) {
+	record := txtLookup("_dmarc."+domain, "v=DMARC1")
+	for _, tag := range strings.Split(record, ";") {
+		if parts := strings.SplitN(strings.TrimSpace(tag), "=", 2); len(parts) == 2 && strings.TrimSpace(parts[0]) == "p" {
+			return record, strings.ToLower(strings.TrimSpace(parts[1]))
+		}
+	}
+	return record, ""
+}
+
+// connects to the SMTP server of host, returns its banner and whether it accepts
+// a recipient that cannot exist at domain; no message is ever sent
+func smtpProbe(host, domain string) (string, bool, error) {
+	addr := host
+	if ip := aLookup(host).ip(); ip != "" {
+		addr = ip
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(addr, smtpPort), smtpTimeout)
+	if err != nil {
+		return "", false, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	text := textproto.NewConn(conn)
+	_, banner, err := text.ReadResponse(220)
+	if err != nil {
+		return banner, false, err
+	}
+	banner = strings.SplitN(banner, "\n", 2)[0]
+	defer text.Cmd("QUIT")
+	if _, _, err = smtpCmd(text, 250, "EHLO dnsmorph.invalid"); err != nil {
+		if _, _, err = smtpCmd(text, 250, "HELO dnsmorph.invalid"); err != nil {
+			return banner, false, err
+		}
+	}
+	if _, _, err = smtpCmd(text, 250, "MAIL FROM:<>"); err != nil {
+		return banner, false, err
+	}
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return banner, false, err
+	}
+	code, _, err := smtpCmd(text, 25, "RCPT TO:<%s@%s>", probeMailbox(), ascii)
+	if err != nil {
+		if _, ok := err.(*textproto.Error); ok {
+			return banner, false, nil
+		}
+		return banner, false, err
+	}
+	return banner, code == 250 || code == 251, nil
+}
+
+// sends an SMTP command and reads the response, expecting a code starting with expectCode
+func smtpCmd(text *textproto.Conn, expectCode int, format string, args ...interface{}) (int, string, error) {
+	id, err := text.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	return text.ReadResponse(expectCode)
+}
+
+// returns an unguessable mailbox name, which no server should accept unless it catches all mail
+func probeMailbox() string {
+	token := make([]byte, 8)
+	rand.Read(token)
+	return fmt.Sprintf("dnsmorph-%x", token)
+}
+
+// returns the mail column, flagging domains accepting mail for any recipient
+func (r *Record) mailLabel() string {
+	if len(r.MX) == 0 {
+		return ""
+	}
+	label := "MX " + r.MX[0]
+	if r.CatchAll {
+		label = fmt.Sprintf("%s (catch-all)", label)
+	}
+	return "\t" + label
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"net"
+	"strings"
+	"testing"
+)
+
+func mxReply(records map[uint16]string) func(q dnsmessage.Question) dnsmessage.Message {
+	return func(q dnsmessage.Question) dnsmessage.Message {
+		var answers []dnsmessage.Resource
+		for pref, host := range records {
+			answers = append(answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeMX, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.MXResource{Pref: pref, MX: dnsmessage.MustNewName(host)},
+			})
+		}
+		return dnsmessage.Message{Answers: answers}
+	}
+}
+
+func txtReply(records ...[]string) func(q dnsmessage.Question) dnsmessage.Message {
+	return func(q dnsmessage.Question) dnsmessage.Message {
+		var answers []dnsmessage.Resource
+		for _, txt := range records {
+			answers = append(answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.TXTResource{TXT: txt},
+			})
+		}
+		return dnsmessage.Message{Answers: answers}
+	}
+}
+
+// dispatches queries for a name to a reply per query type, answering NODATA otherwise
+func byType(replies map[dnsmessage.Type]func(q dnsmessage.Question) dnsmessage.Message) func(q dnsmessage.Question) dnsmessage.Message {
+	return func(q dnsmessage.Question) dnsmessage.Message {
+		if reply, ok := replies[q.Type]; ok {
+			return reply(q)
+		}
+		return dnsmessage.Message{}
+	}
+}
+
+// starts an smtp server accepting every recipient when catchAll is set, returns its port
+func startSMTPStub(t *testing.T, catchAll bool) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen on tcp:", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				fmt.Fprint(conn, "220 mx.exampel.test ESMTP ready\r\n")
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					command := strings.ToUpper(scanner.Text())
+					switch {
+					case strings.HasPrefix(command, "EHLO"):
+						fmt.Fprint(conn, "250-mx.exampel.test\r\n250 8BITMIME\r\n")
+					case strings.HasPrefix(command, "RCPT") && !catchAll:
+						fmt.Fprint(conn, "550 5.1.1 user unknown\r\n")
+					case strings.HasPrefix(command, "QUIT"):
+						fmt.Fprint(conn, "221 bye\r\n")
+						return
+					default:
+						fmt.Fprint(conn, "250 OK\r\n")
+					}
+				}
+			}(conn)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+func TestMailLookup(t *testing.T) {
+	useResolver(t, startDNSStub(t, stubZone{
+		"exampel.test.": byType(map[dnsmessage.Type]func(q dnsmessage.Question) dnsmessage.Message{
+			dnsmessage.TypeMX:  mxReply(map[uint16]string{20: "mx2.exampel.test.", 10: "MX.exampel.test."}),
+			dnsmessage.TypeTXT: txtReply([]string{"google-site-verification=abc"}, []string{"v=spf1 include:_spf.example.net ", "~all"}),
+		}),
+		"_dmarc.exampel.test.": txtReply([]string{"v=DMARC1; p=reject; rua=mailto:dmarc@exampel.test"}),
+		"implicit.test.":       byType(map[dnsmessage.Type]func(q dnsmessage.Question) dnsmessage.Message{dnsmessage.TypeA: aReply("192.0.2.1")}),
+		"nullmx.test.":         mxReply(map[uint16]string{0: "."}),
+		"nomail.test.":         byType(nil),
+	}))
+	for _, test := range []struct {
+		domain   string
+		mx       string
+		fallback bool
+	}{
+		{"exampel.test", "mx.exampel.test", false},
+		{"implicit.test", "implicit.test", true},
+		{"nullmx.test", "", false},
+		{"nomail.test", "", false},
+	} {
+		mx, fallback := mxLookup(test.domain)
+		if first := strings.Join(mx, " "); !strings.HasPrefix(first, test.mx) || (test.mx == "") != (len(mx) == 0) {
+			t.Errorf("%s: expected mx %s, got %v", test.domain, test.mx, mx)
+		}
+		if fallback != test.fallback {
+			t.Errorf("%s: expected fallback %t, got %t", test.domain, test.fallback, fallback)
+		}
+	}
+	if spf, all := spfLookup("exampel.test"); spf != "v=spf1 include:_spf.example.net ~all" || all != "~all" {
+		t.Errorf("unexpected spf '%s' %s", spf, all)
+	}
+	if spf, _ := spfLookup("nomail.test"); spf != "" {
+		t.Errorf("unexpected spf '%s'", spf)
+	}
+	if dmarc, policy := dmarcLookup("exampel.test"); !strings.HasPrefix(dmarc, "v=DMARC1") || policy != "reject" {
+		t.Errorf("unexpected dmarc '%s' %s", dmarc, policy)
+	}
+}
+
+func TestSMTPProbe(t *testing.T) {
+	useResolver(t, startDNSStub(t, stubZone{"mx.exampel.test.": aReply("127.0.0.1")}))
+	previous := smtpPort
+	defer func() { smtpPort = previous }()
+	for _, catchAll := range []bool{true, false} {
+		smtpPort = startSMTPStub(t, catchAll)
+		banner, accepted, err := smtpProbe("mx.exampel.test", "exampel.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if banner != "mx.exampel.test ESMTP ready" {
+			t.Errorf("unexpected banner '%s'", banner)
+		}
+		if accepted != catchAll {
+			t.Errorf("expected catch-all %t, got %t", catchAll, accepted)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// markdownColumns are the csv columns shown in markdown tables, columns empty for every record are left out
+var markdownColumns = []string{"domain", "status", "registration", "a_record", "geolocation", "registrar",
+	"whoiscreation", "similarity", "cert_issuer", "mx"}
+
+// markdownOutput writes the result set as GitHub flavoured markdown tables grouped by technique
+type markdownOutput struct {
+	w       io.Writer
+	records []Record
+}
+
+func newMarkdownOutput(w io.Writer) outputWriter {
+	return &markdownOutput{w: w}
+}
+
+func (o *markdownOutput) write(r *Record) error {
+	o.records = append(o.records, *r)
+	return nil
+}
+
+// returns the index of a csv column
+func csvColumn(name string) int {
+	for i, column := range csvColumns {
+		if column == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// escapes a markdown table cell
+func markdownCell(value string) string {
+	value = strings.NewReplacer("|", `\|`, "\r", " ", "\n", " ").Replace(value)
+	return strings.TrimSpace(value)
+}
+
+// renders the result set, techniques in order of first appearance
+func (o *markdownOutput) render(now time.Time) string {
+	var targets, techniques []string
+	rows := make(map[string][][]string)
+	registeredPerTechnique := make(map[string]int)
+	var registeredCount, live int
+	used := make([]bool, len(markdownColumns))
+	for i := range o.records {
+		r := &o.records[i]
+		targets = appendUnique(targets, r.Target)
+		techniques = appendUnique(techniques, r.Technique)
+		if r.Registration == registered {
+			registeredCount++
+			registeredPerTechnique[r.Technique]++
+		}
+		if r.live() {
+			live++
+		}
+		data := r.csvData()
+		var row []string
+		for j, column := range markdownColumns {
+			value := data[csvColumn(column)]
+			// a zero similarity means the landing page was not probed
+			if column == "similarity" && r.HTTP == nil && r.HTTPS == nil {
+				value = ""
+			} else if column == "similarity" && r.RedirectsToTarget {
+				value = "redirects to target"
+			}
+			if column == "registration" {
+				value = r.registrationLabel()
+			}
+			used[j] = used[j] || value != ""
+			row = append(row, markdownCell(value))
+		}
+		rows[r.Technique] = append(rows[r.Technique], row)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Lookalike domains of %s\n\n", strings.Join(targets, ", "))
+	fmt.Fprintf(&b, "Generated %s by dnsmorph %s.\n\n", now.Format("2006-01-02 15:04 MST"), version)
+	fmt.Fprintf(&b, "| Permutations | Registered | Live |\n| ---: | ---: | ---: |\n| %d | %d | %d |\n",
+		len(o.records), registeredCount, live)
+	for _, technique := range techniques {
+		fmt.Fprintf(&b, "\n## %s\n\n%d permutations, %d registered\n\n", technique, len(rows[technique]), registeredPerTechnique[technique])
+		var header, separator []string
+		for j, column := range markdownColumns {
+			if used[j] {
+				header = append(header, column)
+				separator = append(separator, "---")
+			}
+		}
+		fmt.Fprintf(&b, "| %s |\n| %s |\n", strings.Join(header, " | "), strings.Join(separator, " | "))
+		for _, row := range rows[technique] {
+			var cells []string
+			for j, cell := range row {
+				if used[j] {
+					cells = append(cells, cell)
+				}
+			}
+			fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+		}
+	}
+	return b.String()
+}
+
+func (o *markdownOutput) close() error {
+	_, err := io.WriteString(o.w, o.render(time.Now()))
+	return err
+}
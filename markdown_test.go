@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarkdownReport(t *testing.T) {
+	o := &markdownOutput{records: []Record{
+		{Target: "example.com", Technique: "omission", Domain: "exmple.com", Status: statusNoError, Registration: registered,
+			A: "192.0.2.1", Registrar: "Example | Registrar"},
+		{Target: "example.com", Technique: "addition", Domain: "examplea.com", Status: statusNXDomain, Registration: unregistered},
+		{Target: "example.com", Technique: "omission", Domain: "exampe.com", Status: statusNXDomain, Registration: unregistered},
+	}}
+	report := o.render(time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC))
+	for _, expected := range []string{
+		"# Lookalike domains of example.com\n",
+		"| 3 | 1 | 1 |\n",
+		"## omission\n\n2 permutations, 1 registered\n\n| domain | status | registration | a_record | registrar |\n| --- | --- | --- | --- | --- |\n" +
+			"| exmple.com | NOERROR | registered | 192.0.2.1 | Example \\| Registrar |\n| exampe.com | NXDOMAIN | unregistered |  |  |\n",
+		"## addition\n\n1 permutations, 0 registered\n",
+	} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("expected %q in\n%s", expected, report)
+		}
+	}
+	// techniques keep their order of appearance
+	if strings.Index(report, "## omission") > strings.Index(report, "## addition") {
+		t.Error("expected techniques in order of appearance")
+	}
+}
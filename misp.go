@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang.org/x/net/idna"
+	"io"
+	"strings"
+	"time"
+)
+
+// MISP object template identifiers
+const (
+	mispDomainIPTemplate = "43b3b146-77eb-4931-b4cc-b66c60f28734"
+	mispWhoisTemplate    = "429faea1-34ff-47af-8a00-7c62d3be5a6a"
+)
+
+// mispOutput writes registered candidates as a MISP event
+type mispOutput struct {
+	w       io.Writer
+	targets []string
+	objects []mispObject
+}
+
+// mispEvent is a MISP event in the MISP json import format
+type mispEvent struct {
+	Event struct {
+		UUID          string       `json:"uuid"`
+		Info          string       `json:"info"`
+		Date          string       `json:"date"`
+		ThreatLevelID string       `json:"threat_level_id"`
+		Analysis      string       `json:"analysis"`
+		Distribution  string       `json:"distribution"`
+		Tag           []mispTag    `json:"Tag"`
+		Object        []mispObject `json:"Object"`
+	} `json:"Event"`
+}
+
+// mispObject is an instance of a MISP object template
+type mispObject struct {
+	UUID            string                `json:"uuid"`
+	Name            string                `json:"name"`
+	MetaCategory    string                `json:"meta-category"`
+	TemplateUUID    string                `json:"template_uuid"`
+	Description     string                `json:"description"`
+	Comment         string                `json:"comment,omitempty"`
+	Attribute       []mispAttribute       `json:"Attribute"`
+	ObjectReference []mispObjectReference `json:"ObjectReference,omitempty"`
+}
+
+// mispAttribute is an attribute of a MISP object
+type mispAttribute struct {
+	UUID           string    `json:"uuid"`
+	ObjectRelation string    `json:"object_relation"`
+	Type           string    `json:"type"`
+	Category       string    `json:"category"`
+	Value          string    `json:"value"`
+	ToIDS          bool      `json:"to_ids"`
+	Comment        string    `json:"comment,omitempty"`
+	Tag            []mispTag `json:"Tag,omitempty"`
+}
+
+// mispObjectReference links two MISP objects
+type mispObjectReference struct {
+	ReferencedUUID   string `json:"referenced_uuid"`
+	RelationshipType string `json:"relationship_type"`
+}
+
+// mispTag is a MISP tag
+type mispTag struct {
+	Name string `json:"name"`
+}
+
+func newMISPOutput(w io.Writer) outputWriter {
+	return &mispOutput{w: w}
+}
+
+// appends an attribute to a MISP object unless its value is empty
+func (o *mispObject) add(relation, attributeType, category, value string, toIDS bool) *mispAttribute {
+	if value == "" {
+		return nil
+	}
+	o.Attribute = append(o.Attribute, mispAttribute{UUID: uuidV4(), ObjectRelation: relation, Type: attributeType,
+		Category: category, Value: value, ToIDS: toIDS})
+	return &o.Attribute[len(o.Attribute)-1]
+}
+
+func (o *mispOutput) write(r *Record) error {
+	o.targets = appendUnique(o.targets, r.Target)
+	if r.Registration != registered || r.Owned || r.Defensive {
+		return nil
+	}
+	domain, err := idna.Lookup.ToASCII(r.Domain)
+	if err != nil {
+		domain = r.Domain
+	}
+	tags := []mispTag{{fmt.Sprintf("dnsmorph:technique=%q", r.Technique)}}
+	comment := fmt.Sprintf("%s permutation of %s", r.Technique, r.Target)
+
+	domainIP := mispObject{UUID: uuidV4(), Name: "domain-ip", MetaCategory: "network", TemplateUUID: mispDomainIPTemplate,
+		Description: "A domain and IP address seen as a tuple in a specific time frame.", Comment: comment}
+	attribute := domainIP.add("domain", "domain", "Network activity", domain, true)
+	attribute.Tag = tags
+	if r.HTTP != nil || r.HTTPS != nil {
+		attribute.Comment = fmt.Sprintf("similarity score %d", r.Similarity)
+	}
+	ips := r.IPs
+	if len(ips) == 0 && r.A != "" {
+		ips = []string{r.A}
+	}
+	for _, ip := range ips {
+		if attribute := domainIP.add("ip", "ip-dst", "Network activity", ip, false); attribute != nil {
+			attribute.Tag = tags
+		}
+	}
+	o.objects = append(o.objects, domainIP)
+
+	whois := mispObject{UUID: uuidV4(), Name: "whois", MetaCategory: "network", TemplateUUID: mispWhoisTemplate,
+		Description: "Whois records information for a domain name or an IP address.", Comment: comment}
+	whois.add("domain", "domain", "Network activity", domain, false)
+	whois.add("registrar", "whois-registrar", "Attribution", r.Registrar, false)
+	whois.add("registrant-org", "whois-registrant-org", "Attribution", r.RegistrantOrg, false)
+	whois.add("creation-date", "datetime", "Other", r.WhoisCreation, false)
+	whois.add("modification-date", "datetime", "Other", r.WhoisModification, false)
+	whois.add("expiration-date", "datetime", "Other", r.WhoisExpiration, false)
+	for _, ns := range r.WhoisNameservers {
+		whois.add("nameserver", "hostname", "Network activity", strings.ToLower(ns), false)
+	}
+	// only the domain attribute means no whois lookup was performed
+	if len(whois.Attribute) > 1 {
+		for i := range whois.Attribute {
+			whois.Attribute[i].Tag = tags
+		}
+		whois.ObjectReference = []mispObjectReference{{domainIP.UUID, "related-to"}}
+		o.objects = append(o.objects, whois)
+	}
+	return nil
+}
+
+func (o *mispOutput) close() error {
+	var event mispEvent
+	event.Event.UUID = uuidV4()
+	event.Event.Info = "dnsmorph lookalike domains of " + strings.Join(o.targets, ", ")
+	event.Event.Date = time.Now().Format("2006-01-02")
+	// low threat level, initial analysis, organisation only distribution
+	event.Event.ThreatLevelID, event.Event.Analysis, event.Event.Distribution = "3", "0", "0"
+	event.Event.Tag = []mispTag{{"dnsmorph"}}
+	event.Event.Object = o.objects
+	if event.Event.Object == nil {
+		event.Event.Object = []mispObject{}
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(o.w, "%s\n", data)
+	return err
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestMISPEvent(t *testing.T) {
+	var buf bytes.Buffer
+	writer := outputFormats["misp"](&buf)
+	for _, r := range []Record{
+		{Target: "example.com", Technique: "transposition", Domain: "exampel.com", IPs: []string{"192.0.2.1"}, Registration: registered,
+			HTTPS: &HTTPProbe{StatusCode: 200}, Similarity: 87, Registrar: "Example Registrar", WhoisCreation: "2020-10-01T00:00:00Z", WhoisNameservers: []string{"NS1.PARKING.EXAMPLE"}},
+		{Target: "example.com", Technique: "addition", Domain: "examplea.com", Registration: registered},
+		{Target: "example.com", Technique: "omission", Domain: "exmple.com", Registration: unregistered},
+		{Target: "example.com", Technique: "hyphenation", Domain: "exam-ple.com", Registration: registered, Owned: true},
+		{Target: "example.com", Technique: "bitsquatting", Domain: "exapmle.com", Registration: registered, Defensive: true},
+	} {
+		if err := writer.write(&r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	var event mispEvent
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Event.Info != "dnsmorph lookalike domains of example.com" {
+		t.Errorf("unexpected event info '%s'", event.Event.Info)
+	}
+	objects := event.Event.Object
+	if len(objects) != 3 {
+		t.Fatalf("expected 3 objects, got %d", len(objects))
+	}
+	domainIP, whois := objects[0], objects[1]
+	if domainIP.TemplateUUID != mispDomainIPTemplate || whois.TemplateUUID != mispWhoisTemplate || objects[2].Name != "domain-ip" {
+		t.Errorf("unexpected objects %s %s %s", domainIP.Name, whois.Name, objects[2].Name)
+	}
+	if len(domainIP.Attribute) != 2 || domainIP.Attribute[0].Value != "exampel.com" || domainIP.Attribute[1].Value != "192.0.2.1" {
+		t.Fatalf("unexpected domain-ip attributes %v", domainIP.Attribute)
+	}
+	domain := domainIP.Attribute[0]
+	if domain.Comment != "similarity score 87" || !domain.ToIDS {
+		t.Errorf("unexpected domain attribute %v", domain)
+	}
+	if comment := objects[2].Attribute[0].Comment; comment != "" {
+		t.Errorf("expected no similarity score without a landing page probe, got '%s'", comment)
+	}
+	if len(domain.Tag) != 1 || domain.Tag[0].Name != `dnsmorph:technique="transposition"` {
+		t.Errorf("unexpected tags %v", domain.Tag)
+	}
+	relations := make(map[string]string)
+	for _, attribute := range whois.Attribute {
+		relations[attribute.ObjectRelation] = attribute.Value
+	}
+	if relations["registrar"] != "Example Registrar" || relations["nameserver"] != "ns1.parking.example" || relations["creation-date"] == "" {
+		t.Errorf("unexpected whois attributes %v", relations)
+	}
+	if len(whois.ObjectReference) != 1 || whois.ObjectReference[0].ReferencedUUID != domainIP.UUID {
+		t.Errorf("expected the whois object to reference the domain-ip object")
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// outputWriter writes records in an output format, formats needing the
+// whole result set buffer records until the writer is closed
+type outputWriter interface {
+	write(r *Record) error
+	close() error
+}
+
+// outputFormats maps output format names to their writer constructors
+var outputFormats = map[string]func(io.Writer) outputWriter{
+	"csv":      newCSVOutput,
+	"json":     newJSONOutput,
+	"ndjson":   newNDJSONOutput,
+	"stix":     newSTIXOutput,
+	"misp":     newMISPOutput,
+	"rpz":      newRPZOutput,
+	"hosts":    newHostsOutput,
+	"dnsmasq":  newDnsmasqOutput,
+	"unbound":  newUnboundOutput,
+	"suricata": newSuricataOutput,
+	"snort":    newSnortOutput,
+	"zeek":     newZeekOutput,
+	"html":     newHTMLOutput,
+	"markdown": newMarkdownOutput,
+	"xlsx":     newXLSXOutput,
+	"template": newTemplateOutput,
+}
+
+// outputExtensions maps file extensions to output format names
+var outputExtensions = map[string]string{
+	".csv":    "csv",
+	".json":   "json",
+	".ndjson": "ndjson",
+	".jsonl":  "ndjson",
+	".stix":   "stix",
+	".rpz":    "rpz",
+	".hosts":  "hosts",
+	".rules":  "suricata",
+	".intel":  "zeek",
+	".html":   "html",
+	".htm":    "html",
+	".md":     "markdown",
+	".xlsx":   "xlsx",
+}
+
+// registrationFormats are the output formats selecting candidates on their registration, implying -r
+var registrationFormats = map[string]bool{
+	"stix":     true,
+	"misp":     true,
+	"rpz":      true,
+	"hosts":    true,
+	"dnsmasq":  true,
+	"unbound":  true,
+	"suricata": true,
+	"snort":    true,
+	"zeek":     true,
+}
+
+// outputList collects the repeatable -o option
+type outputList []string
+
+func (o *outputList) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *outputList) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+// output is an output destination, a path of "-" writes to stdout
+type output struct {
+	path   string
+	format string
+	file   io.WriteCloser
+	writer outputWriter
+}
+
+// csvOutput writes one csv row per record
+type csvOutput struct {
+	writer *csv.Writer
+}
+
+// jsonOutput writes the result set as a single json document
+type jsonOutput struct {
+	w       io.Writer
+	results OutJSON
+}
+
+func newCSVOutput(w io.Writer) outputWriter {
+	return &csvOutput{csv.NewWriter(w)}
+}
+
+func (o *csvOutput) write(r *Record) error {
+	return o.writer.Write(r.csvData())
+}
+
+func (o *csvOutput) close() error {
+	o.writer.Flush()
+	return o.writer.Error()
+}
+
+func newJSONOutput(w io.Writer) outputWriter {
+	return &jsonOutput{w: w}
+}
+
+func (o *jsonOutput) write(r *Record) error {
+	o.results.Results = append(o.results.Results, *r)
+	return nil
+}
+
+func (o *jsonOutput) close() error {
+	data, err := json.Marshal(o.results)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(o.w, "%s\n", data)
+	return err
+}
+
+// ndjsonOutput writes one json document per record as soon as it is available
+type ndjsonOutput struct {
+	encoder *json.Encoder
+}
+
+func newNDJSONOutput(w io.Writer) outputWriter {
+	return &ndjsonOutput{json.NewEncoder(w)}
+}
+
+func (o *ndjsonOutput) write(r *Record) error {
+	return o.encoder.Encode(r)
+}
+
+func (o *ndjsonOutput) close() error {
+	return nil
+}
+
+// returns the sorted names of the output formats
+func formatNames() []string {
+	names := make([]string, 0, len(outputFormats))
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// returns the format of an output path, given explicitly or inferred from its extension
+func outputFormat(path, format string) (string, error) {
+	if format == "" {
+		format = outputExtensions[strings.ToLower(filepath.Ext(path))]
+		if format == "" {
+			return "", fmt.Errorf("cannot infer the output format of %s, please supply option -format", path)
+		}
+	}
+	if _, ok := outputFormats[format]; !ok {
+		return "", fmt.Errorf("unknown output format %s, supported formats are %s", format, strings.Join(formatNames(), ", "))
+	}
+	return format, nil
+}
+
+// splits an -o value into its path and format, the format being given by a format: prefix,
+// by the -format option or inferred from the extension
+func parseOutputPath(value, format string) (string, string, error) {
+	if parts := strings.SplitN(value, ":", 2); len(parts) == 2 && outputFormats[parts[0]] != nil {
+		value, format = parts[1], parts[0]
+	}
+	format, err := outputFormat(value, format)
+	return value, format, err
+}
+
+// opens the output destination and creates its writer, warning before overwriting a file
+func (o *output) open() error {
+	if o.path == "-" {
+		o.writer = outputFormats[o.format](os.Stdout)
+		return nil
+	}
+	if _, err := os.Stat(o.path); err == nil {
+		y.Fprintf(os.Stderr, "[!] overwriting %s\n", o.path)
+	}
+	file, err := os.Create(o.path)
+	if err != nil {
+		return err
+	}
+	o.file = file
+	o.writer = outputFormats[o.format](file)
+	return nil
+}
+
+// closes the output writer and its file
+func (o *output) close() error {
+	err := o.writer.close()
+	if o.file != nil {
+		if cerr := o.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// reports whether any output selects candidates on their registration
+func registrationOutputs(outputs []*output) bool {
+	for _, o := range outputs {
+		if registrationFormats[o.format] {
+			return true
+		}
+	}
+	return false
+}
+
+// reports whether any output renders the -template file
+func templateOutputs(outputs []*output) bool {
+	for _, o := range outputs {
+		if o.format == "template" {
+			return true
+		}
+	}
+	return false
+}
+
+// reports whether any output writes to stdout
+func stdoutOutput(outputs []*output) bool {
+	for _, o := range outputs {
+		if o.path == "-" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestOutputFormat(t *testing.T) {
+	for _, test := range []struct {
+		path   string
+		format string
+		result string
+		fails  bool
+	}{
+		{"results.csv", "", "csv", false},
+		{"out/Results.JSON", "", "json", false},
+		{"-", "json", "json", false},
+		{"results.txt", "csv", "csv", false},
+		{"results.txt", "", "", true},
+		{"-", "", "", true},
+		{"results.csv", "yaml", "", true},
+		{"results.jsonl", "", "ndjson", false},
+		{"-", "ndjson", "ndjson", false},
+	} {
+		format, err := outputFormat(test.path, test.format)
+		if (err != nil) != test.fails {
+			t.Errorf("%s %s: unexpected error %v", test.path, test.format, err)
+		}
+		if format != test.result {
+			t.Errorf("%s %s: expected format '%s', got '%s'", test.path, test.format, test.result, format)
+		}
+	}
+}
+
+func TestParseOutputPath(t *testing.T) {
+	for _, test := range []struct {
+		value  string
+		path   string
+		format string
+	}{
+		{"results.csv", "results.csv", "csv"},
+		{"dnsmasq:blocked.conf", "blocked.conf", "dnsmasq"},
+		{"ndjson:-", "-", "ndjson"},
+		{"c:results.json", "c:results.json", "json"},
+	} {
+		path, format, err := parseOutputPath(test.value, "")
+		if err != nil || path != test.path || format != test.format {
+			t.Errorf("%s: expected %s %s, got %s %s (%v)", test.value, test.format, test.path, format, path, err)
+		}
+	}
+}
+
+func TestOutputWriters(t *testing.T) {
+	records := []Record{
+		{Target: "example.com", Technique: "addition", Domain: "examplea.com", A: "192.0.2.1"},
+		{Target: "example.com", Technique: "omission", Domain: "exmple.com"},
+	}
+	var csvBuf, jsonBuf bytes.Buffer
+	writers := []outputWriter{outputFormats["csv"](&csvBuf), outputFormats["json"](&jsonBuf)}
+	for i := range records {
+		for _, writer := range writers {
+			if err := writer.write(&records[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, writer := range writers {
+		if err := writer.close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := csv.NewReader(&csvBuf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0][1] != "examplea.com" || rows[0][2] != "192.0.2.1" {
+		t.Errorf("unexpected csv output %v", rows)
+	}
+	var results OutJSON
+	if err := json.Unmarshal(jsonBuf.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 2 || results.Results[1].Domain != "exmple.com" {
+		t.Errorf("unexpected json output %v", results.Results)
+	}
+}
+
+func TestNDJSONOutput(t *testing.T) {
+	var buf bytes.Buffer
+	writer := outputFormats["ndjson"](&buf)
+	for _, domain := range []string{"examplea.com", "exmple.com"} {
+		if err := writer.write(&Record{Target: "example.com", Domain: domain}); err != nil {
+			t.Fatal(err)
+		}
+		// every record is written as soon as it is received
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		var r Record
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &r); err != nil || r.Domain != domain {
+			t.Errorf("expected %s on the last line, got '%s' (%v)", domain, lines[len(lines)-1], err)
+		}
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 2 {
+		t.Errorf("expected 2 lines, got %d", lines)
+	}
+}
+
+func TestDecodeResults(t *testing.T) {
+	for _, input := range []string{
+		`{"results":[{"domain":"examplea.com"},{"domain":"exmple.com"}]}`,
+		"{\"domain\":\"examplea.com\"}\n{\"domain\":\"exmple.com\"}\n",
+	} {
+		records, err := decodeResults(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 2 || records[0].Domain != "examplea.com" || records[1].Domain != "exmple.com" {
+			t.Errorf("unexpected records %v", records)
+		}
+	}
+}
+
+func TestRegistrationOutputs(t *testing.T) {
+	for format, expected := range map[string]bool{"csv": false, "ndjson": false, "stix": true, "misp": true, "rpz": true, "zeek": true} {
+		if result := registrationOutputs([]*output{{path: "-", format: format}}); result != expected {
+			t.Errorf("%s: expected registration lookups %v, got %v", format, expected, result)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"golang.org/x/net/html"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+)
+
+//go:embed signatures/parking.json
+var defaultParkingSignatures []byte
+
+// ParkingSignatures holds the indicators of parked and for-sale domains
+type ParkingSignatures struct {
+	Nameservers    []string `json:"nameservers"`
+	IPRanges       []string `json:"ip_ranges"`
+	RedirectHosts  []string `json:"redirect_hosts"`
+	ParkedMarkers  []string `json:"parked_markers"`
+	ForSaleMarkers []string `json:"for_sale_markers"`
+	networks       []*net.IPNet
+}
+
+var parking *ParkingSignatures
+
+// parses a parking signature set in json format
+func parseParkingSignatures(data []byte) (*ParkingSignatures, error) {
+	signatures := new(ParkingSignatures)
+	if err := json.Unmarshal(data, signatures); err != nil {
+		return nil, err
+	}
+	for _, cidr := range signatures.IPRanges {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		signatures.networks = append(signatures.networks, network)
+	}
+	for i, marker := range signatures.ParkedMarkers {
+		signatures.ParkedMarkers[i] = strings.ToLower(marker)
+	}
+	for i, marker := range signatures.ForSaleMarkers {
+		signatures.ForSaleMarkers[i] = strings.ToLower(marker)
+	}
+	return signatures, nil
+}
+
+// loads the parking signatures from path, or the embedded set when path is empty
+func loadParkingSignatures(path string) (*ParkingSignatures, error) {
+	data := defaultParkingSignatures
+	if path != "" {
+		var err error
+		if data, err = ioutil.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return parseParkingSignatures(data)
+}
+
+// returns the lowercased visible text of an html page, without scripts, styles and markup
+func visibleText(body []byte) string {
+	var words []string
+	skip := 0
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+		switch tt {
+		case html.StartTagToken, html.EndTagToken:
+			switch token.Data {
+			case "script", "style", "noscript", "template":
+				if tt == html.StartTagToken {
+					skip++
+				} else if skip > 0 {
+					skip--
+				}
+			}
+		case html.TextToken:
+			if skip == 0 {
+				words = append(words, strings.Fields(strings.ToLower(token.Data))...)
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// classifies a Record as parked or for sale from its name servers, addresses and
+// landing page, returns the verdicts and the first matching indicator
+func (s *ParkingSignatures) classify(r *Record) (parked, forSale bool, reason string) {
+	if s == nil {
+		return false, false, ""
+	}
+	note := func(indicator string) {
+		if reason == "" {
+			reason = indicator
+		}
+	}
+	for _, host := range append(append([]string{}, r.NS...), r.WhoisNameservers...) {
+		for _, ns := range s.Nameservers {
+			if matchesDomain(host, ns) {
+				parked = true
+				note("ns " + ns)
+			}
+		}
+	}
+	for _, ip := range append([]string{r.A}, r.IPs...) {
+		parsed := net.ParseIP(ip)
+		for _, network := range s.networks {
+			if parsed != nil && network.Contains(parsed) {
+				parked = true
+				note("ip " + network.String())
+			}
+		}
+	}
+	for _, probe := range []*HTTPProbe{r.HTTPS, r.HTTP} {
+		if probe == nil {
+			continue
+		}
+		if final, err := url.Parse(probe.FinalURL); err == nil && final.Hostname() != "" {
+			for _, host := range s.RedirectHosts {
+				if matchesDomain(final.Hostname(), host) {
+					forSale = true
+					note("redirect " + host)
+				}
+			}
+		}
+		content := strings.Join(strings.Fields(strings.ToLower(probe.Title)), " ") + " " + visibleText(probe.body)
+		for _, marker := range s.ForSaleMarkers {
+			if strings.Contains(content, marker) {
+				forSale = true
+				note("html \"" + marker + "\"")
+			}
+		}
+		for _, marker := range s.ParkedMarkers {
+			if strings.Contains(content, marker) {
+				parked = true
+				note("html \"" + marker + "\"")
+			}
+		}
+	}
+	return parked, forSale, reason
+}
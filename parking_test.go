@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParkingSignatures(t *testing.T) {
+	signatures, err := loadParkingSignatures("")
+	if err != nil {
+		t.Fatal("embedded signatures failed to load:", err)
+	}
+	for _, test := range []struct {
+		record  Record
+		parked  bool
+		forSale bool
+		reason  string
+	}{
+		{Record{NS: []string{"ns1.sedoparking.com", "ns2.sedoparking.com"}}, true, false, "ns sedoparking.com"},
+		{Record{A: "199.59.242.150"}, true, false, "ip 199.59.240.0/22"},
+		{Record{HTTPS: &HTTPProbe{FinalURL: "https://www.dan.com/buy-domain/examp1e.com"}}, false, true, "redirect dan.com"},
+		{Record{HTTP: &HTTPProbe{Title: "examp1e.com", body: []byte("<h1>This domain may be for sale!</h1>")}}, false, true,
+			"html \"this domain may be for sale\""},
+		{Record{HTTP: &HTTPProbe{body: []byte("<p>This domain is <b>for sale</b></p>")}}, false, true, "html \"this domain is for sale\""},
+		{Record{NS: []string{"ns1.example.com"}, A: "192.0.2.1", HTTP: &HTTPProbe{body: []byte("<h1>Welcome</h1>")}}, false, false, ""},
+		{Record{HTTP: &HTTPProbe{body: []byte(`<script>var ad = "this domain is parked";</script><style>/* buy this domain */</style>` +
+			`<h1>Shop</h1><h2>Related links</h2>`)}}, false, false, ""},
+	} {
+		parked, forSale, reason := signatures.classify(&test.record)
+		if parked != test.parked || forSale != test.forSale || reason != test.reason {
+			t.Errorf("expected %v/%v/%s, got %v/%v/%s", test.parked, test.forSale, test.reason, parked, forSale, reason)
+		}
+	}
+}
+
+func TestParseParkingSignatures(t *testing.T) {
+	signatures, err := parseParkingSignatures([]byte(`{"nameservers": ["park.example"], "for_sale_markers": ["Buy Me"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, forSale, _ := signatures.classify(&Record{HTTP: &HTTPProbe{body: []byte("buy me now")}})
+	if !forSale {
+		t.Error("expected markers to match case insensitively")
+	}
+	if _, err := parseParkingSignatures([]byte(`{"ip_ranges": ["192.0.2.0"]}`)); err == nil {
+		t.Error("expected invalid ip range to fail")
+	}
+}
+
+func TestParkedLabel(t *testing.T) {
+	for _, test := range []struct {
+		record   Record
+		expected string
+	}{
+		{Record{Registration: registered, Parked: true}, registered + " (parked)"},
+		{Record{Registration: registered, ForSale: true, Parked: true}, registered + " (for sale)"},
+		{Record{Registration: registered, Parked: true, LoginForm: true}, registered},
+		{Record{Registration: registered, ForSale: true, Similarity: similarityThreshold}, registered},
+	} {
+		if label := test.record.registrationLabel(); label != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, label)
+		}
+	}
+}
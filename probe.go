@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"golang.org/x/net/html"
+	"golang.org/x/net/idna"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maximum number of body bytes read by the http probe
+const maxProbeBody = 1 << 20
+
+var (
+	probeClient     *http.Client
+	probeClientOnce sync.Once
+)
+
+// HTTPProbe holds the response of a landing page request
+type HTTPProbe struct {
+	StatusCode    int    `json:"status_code"`
+	FinalURL      string `json:"final_url"`
+	Title         string `json:"title"`
+	Server        string `json:"server"`
+	ContentLength int64  `json:"content_length"`
+	Error         string `json:"error,omitempty"`
+	body          []byte
+}
+
+// drops the page bodies of probes once analysed, as outputs buffer records until closed
+func releaseBodies(probes ...*HTTPProbe) {
+	for _, probe := range probes {
+		if probe != nil {
+			probe.body = nil
+		}
+	}
+}
+
+// returns the http client shared by probes, certificate errors are ignored
+// since lookalike domains rarely serve valid certificates
+func httpProbeClient() *http.Client {
+	probeClientOnce.Do(func() {
+		probeClient = &http.Client{
+			Timeout: *httpTimeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		}
+	})
+	return probeClient
+}
+
+// extracts the content of the title element of an html document
+func pageTitle(body []byte) string {
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "title" {
+				if tokenizer.Next() == html.TextToken {
+					return strings.Join(strings.Fields(string(tokenizer.Text())), " ")
+				}
+				return ""
+			}
+		}
+	}
+}
+
+// fetches url and records status, final url after redirects, title, server and length
+func probeURL(client *http.Client, url, userAgent string) *HTTPProbe {
+	probe := new(HTTPProbe)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	defer resp.Body.Close()
+	probe.body, _ = ioutil.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	probe.StatusCode = resp.StatusCode
+	probe.FinalURL = resp.Request.URL.String()
+	probe.Server = resp.Header.Get("Server")
+	probe.ContentLength = resp.ContentLength
+	if probe.ContentLength < 0 {
+		probe.ContentLength = int64(len(probe.body))
+	}
+	probe.Title = pageTitle(probe.body)
+	return probe
+}
+
+// probes the landing page of domain over http and https
+func httpLookup(domain string) (*HTTPProbe, *HTTPProbe) {
+	host, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return &HTTPProbe{Error: err.Error()}, &HTTPProbe{Error: err.Error()}
+	}
+	client := httpProbeClient()
+	return probeURL(client, "http://"+host+"/", *userAgent), probeURL(client, "https://"+host+"/", *userAgent)
+}
+
+// formats an http probe as csv fields
+func (p *HTTPProbe) csvData() []string {
+	if p == nil {
+		return []string{"", "", "", "", ""}
+	}
+	status, length := "", ""
+	if p.StatusCode != 0 {
+		status = strconv.Itoa(p.StatusCode)
+		length = strconv.FormatInt(p.ContentLength, 10)
+	}
+	return []string{status, p.FinalURL, p.Title, p.Server, length}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const probePage = "<html><head><title>\n  Example   Login\n</title></head><body>welcome</body></html>"
+
+func TestProbeURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "dnsmorph-test" {
+			http.Error(w, "unexpected user agent", http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, "/landing", http.StatusFound)
+	})
+	mux.HandleFunc("/landing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx")
+		fmt.Fprint(w, probePage)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	probe := probeURL(server.Client(), server.URL+"/", "dnsmorph-test")
+	if probe.Error != "" {
+		t.Fatal("probe failed:", probe.Error)
+	}
+	if probe.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", probe.StatusCode)
+	}
+	if probe.FinalURL != server.URL+"/landing" {
+		t.Errorf("expected final url %s/landing, got %s", server.URL, probe.FinalURL)
+	}
+	if probe.Title != "Example Login" {
+		t.Errorf("expected title 'Example Login', got '%s'", probe.Title)
+	}
+	if probe.Server != "nginx" {
+		t.Errorf("expected server 'nginx', got '%s'", probe.Server)
+	}
+	if probe.ContentLength != int64(len(probePage)) {
+		t.Errorf("expected content length %d, got %d", len(probePage), probe.ContentLength)
+	}
+}
+
+func TestProbeURLTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	// the shared probe client must accept the self-signed test certificate
+	probe := probeURL(httpProbeClient(), server.URL+"/", "dnsmorph-test")
+	if probe.Error != "" || probe.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d (%s)", probe.StatusCode, probe.Error)
+	}
+	if probe.Title != "" {
+		t.Errorf("expected empty title, got '%s'", probe.Title)
+	}
+}
+
+func TestProbeURLError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	probe := probeURL(server.Client(), url, "dnsmorph-test")
+	if probe.Error == "" || probe.StatusCode != 0 {
+		t.Errorf("expected connection error, got status %d", probe.StatusCode)
+	}
+	if data := probe.csvData(); data[0] != "" {
+		t.Errorf("expected empty csv status, got '%s'", data[0])
+	}
+}
+
+func TestReleaseBodies(t *testing.T) {
+	probe := &HTTPProbe{StatusCode: 200, Title: "Example", body: []byte("<title>Example</title>")}
+	releaseBodies(probe, nil)
+	if probe.body != nil || probe.Title != "Example" {
+		t.Errorf("expected only the body to be released, got %+v", probe)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/likexian/whois-go"
+	"github.com/likexian/whois-parser-go"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	rdapBootstrapURL    = "https://data.iana.org/rdap/dns.json"
+	rdapIPBootstrapURL  = "https://data.iana.org/rdap/ipv4.json"
+	rdapClient          = &http.Client{Timeout: 15 * time.Second}
+	rdapBootstrapOnce   sync.Once
+	rdapIPBootstrapOnce sync.Once
+	rdapServices        map[string][]string
+	rdapIPServices      []rdapIPService
+	errNoRDAP           = errors.New("no rdap service available")
+	errNotFound         = errors.New("domain not found")
+	abuseEmailRegexp    = regexp.MustCompile(`(?i)abuse contact email:\s*(\S+@\S+)`)
+)
+
+// WhoisRecord holds the registration data of a domain
+type WhoisRecord struct {
+	Source        string   `json:"source"`
+	Created       string   `json:"created"`
+	Updated       string   `json:"updated"`
+	Expiry        string   `json:"expiry"`
+	Registrar     string   `json:"registrar"`
+	RegistrantOrg string   `json:"registrant_org"`
+	Status        []string `json:"status"`
+	Nameservers   []string `json:"nameservers"`
+	AbuseEmail    string   `json:"abuse_email"`
+}
+
+// rdapBootstrap mirrors the IANA bootstrap registry format (RFC 7484)
+type rdapBootstrap struct {
+	Services [][][]string `json:"services"`
+}
+
+// rdapIPService maps an address block to its RDAP servers
+type rdapIPService struct {
+	network *net.IPNet
+	urls    []string
+}
+
+// rdapIPNetwork is the subset of an RDAP ip network response used by dnsmorph
+type rdapIPNetwork struct {
+	Name     string       `json:"name"`
+	Entities []rdapEntity `json:"entities"`
+}
+
+// rdapEntity is a contact object of an RDAP response
+type rdapEntity struct {
+	Roles      []string        `json:"roles"`
+	VcardArray json.RawMessage `json:"vcardArray"`
+	Entities   []rdapEntity    `json:"entities"`
+}
+
+// rdapDomain is the subset of an RDAP domain response used by dnsmorph
+type rdapDomain struct {
+	LdhName string   `json:"ldhName"`
+	Status  []string `json:"status"`
+	Events  []struct {
+		EventAction string `json:"eventAction"`
+		EventDate   string `json:"eventDate"`
+	} `json:"events"`
+	Nameservers []struct {
+		LdhName string `json:"ldhName"`
+	} `json:"nameservers"`
+	Entities []rdapEntity `json:"entities"`
+}
+
+// returns the first value of a vcard property, empty if absent
+func (e *rdapEntity) vcard(property string) string {
+	var vcard []json.RawMessage
+	if json.Unmarshal(e.VcardArray, &vcard) != nil || len(vcard) < 2 {
+		return ""
+	}
+	var properties [][]json.RawMessage
+	if json.Unmarshal(vcard[1], &properties) != nil {
+		return ""
+	}
+	for _, p := range properties {
+		var name, value string
+		if len(p) < 4 || json.Unmarshal(p[0], &name) != nil || name != property {
+			continue
+		}
+		if json.Unmarshal(p[3], &value) == nil {
+			return value
+		}
+		// structured values such as org are arrays of strings
+		var values []string
+		if json.Unmarshal(p[3], &values) == nil && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// reports whether the entity holds the given role
+func (e *rdapEntity) hasRole(role string) bool {
+	for _, r := range e.Roles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// finds the abuse email among an entity and its nested entities
+func abuseEmail(entities []rdapEntity) string {
+	for _, e := range entities {
+		if e.hasRole("abuse") {
+			if email := e.vcard("email"); email != "" {
+				return email
+			}
+		}
+		if email := abuseEmail(e.Entities); email != "" {
+			return email
+		}
+	}
+	return ""
+}
+
+// fetches and indexes the IANA RDAP bootstrap registry once per run
+func loadRDAPBootstrap() {
+	rdapBootstrapOnce.Do(func() {
+		rdapServices = make(map[string][]string)
+		var bootstrap rdapBootstrap
+		if err := getJSON(rdapBootstrapURL, &bootstrap); err != nil {
+			return
+		}
+		for _, service := range bootstrap.Services {
+			if len(service) < 2 {
+				continue
+			}
+			for _, tld := range service[0] {
+				rdapServices[strings.ToLower(tld)] = service[1]
+			}
+		}
+	})
+}
+
+// fetches and indexes the IANA RDAP bootstrap registry for IPv4 addresses once per run
+func loadRDAPIPBootstrap() {
+	rdapIPBootstrapOnce.Do(func() {
+		rdapIPServices = nil
+		var bootstrap rdapBootstrap
+		if err := getJSON(rdapIPBootstrapURL, &bootstrap); err != nil {
+			return
+		}
+		for _, service := range bootstrap.Services {
+			if len(service) < 2 {
+				continue
+			}
+			for _, block := range service[0] {
+				if _, network, err := net.ParseCIDR(block); err == nil {
+					rdapIPServices = append(rdapIPServices, rdapIPService{network, service[1]})
+				}
+			}
+		}
+	})
+}
+
+// performs an http GET request and decodes the json response into v
+func getJSON(url string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/rdap+json, application/json")
+	resp, err := rdapClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rdap server returned %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// returns the RDAP base url serving tld
+func rdapServer(tld string) (string, error) {
+	loadRDAPBootstrap()
+	return rdapBaseURL(rdapServices[strings.ToLower(tld)])
+}
+
+// returns the RDAP base url serving an IPv4 address
+func rdapIPServer(ip net.IP) (string, error) {
+	loadRDAPIPBootstrap()
+	for _, service := range rdapIPServices {
+		if service.network.Contains(ip) {
+			return rdapBaseURL(service.urls)
+		}
+	}
+	return "", errNoRDAP
+}
+
+// picks a base url among the urls of a bootstrap service, preferring https
+func rdapBaseURL(urls []string) (string, error) {
+	if len(urls) == 0 {
+		return "", errNoRDAP
+	}
+	server := urls[0]
+	for _, u := range urls {
+		if strings.HasPrefix(u, "https://") {
+			server = u
+			break
+		}
+	}
+	if !strings.HasSuffix(server, "/") {
+		server += "/"
+	}
+	return server, nil
+}
+
+// performs an RDAP domain lookup using the IANA bootstrap registry
+func rdapLookup(inputDomain string) (WhoisRecord, error) {
+	record := WhoisRecord{Source: "rdap"}
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(inputDomain))
+	if err != nil {
+		return record, err
+	}
+	ascii, err := idna.Lookup.ToASCII(registrable)
+	if err != nil {
+		return record, err
+	}
+	server, err := rdapServer(ascii[strings.LastIndex(ascii, ".")+1:])
+	if err != nil {
+		return record, err
+	}
+	var response rdapDomain
+	if err := getJSON(server+"domain/"+ascii, &response); err != nil {
+		return record, err
+	}
+	record.Status = response.Status
+	for _, event := range response.Events {
+		switch event.EventAction {
+		case "registration":
+			record.Created = event.EventDate
+		case "last changed":
+			record.Updated = event.EventDate
+		case "expiration":
+			record.Expiry = event.EventDate
+		}
+	}
+	for _, ns := range response.Nameservers {
+		record.Nameservers = append(record.Nameservers, strings.ToLower(ns.LdhName))
+	}
+	for _, e := range response.Entities {
+		switch {
+		case e.hasRole("registrar"):
+			record.Registrar = e.vcard("fn")
+			if email := abuseEmail(e.Entities); email != "" {
+				record.AbuseEmail = email
+			}
+		case e.hasRole("registrant"):
+			if record.RegistrantOrg = e.vcard("org"); record.RegistrantOrg == "" {
+				record.RegistrantOrg = e.vcard("fn")
+			}
+		}
+	}
+	if record.AbuseEmail == "" {
+		record.AbuseEmail = abuseEmail(response.Entities)
+	}
+	return record, nil
+}
+
+// performs an RDAP lookup on input IP, returns the network owner and its abuse email
+func ipAbuseLookup(inputIP string) (string, string, error) {
+	ip := net.ParseIP(inputIP).To4()
+	if ip == nil {
+		return "", "", fmt.Errorf("invalid ipv4 address %q", inputIP)
+	}
+	server, err := rdapIPServer(ip)
+	if err != nil {
+		return "", "", err
+	}
+	var response rdapIPNetwork
+	if err := getJSON(server+"ip/"+ip.String(), &response); err != nil {
+		return "", "", err
+	}
+	owner := response.Name
+	for _, e := range response.Entities {
+		if e.hasRole("registrant") {
+			if fn := e.vcard("fn"); fn != "" {
+				owner = fn
+			}
+			break
+		}
+	}
+	return owner, abuseEmail(response.Entities), nil
+}
+
+// performs a whois lookup on input domain by parsing raw whois text
+func rawWhoisLookup(inputDomain string) (WhoisRecord, error) {
+	record := WhoisRecord{Source: "whois"}
+	whoisRaw, err := whois.Whois(inputDomain)
+	if err != nil {
+		return record, err
+	}
+	result, err := whoisparser.Parse(whoisRaw)
+	if err != nil {
+		return record, err
+	}
+	if result.Domain != nil {
+		record.Created = result.Domain.CreatedDate
+		record.Updated = result.Domain.UpdatedDate
+		record.Expiry = result.Domain.ExpirationDate
+		record.Status = result.Domain.Status
+		record.Nameservers = result.Domain.NameServers
+	}
+	if result.Registrar != nil {
+		record.Registrar = result.Registrar.Name
+	}
+	if result.Registrant != nil {
+		record.RegistrantOrg = result.Registrant.Organization
+	}
+	if match := abuseEmailRegexp.FindStringSubmatch(whoisRaw); match != nil {
+		record.AbuseEmail = match[1]
+	}
+	return record, nil
+}
+
+// performs a registration data lookup on input domain, using RDAP
+// and falling back to whois where RDAP is unavailable
+func whoisLookup(inputDomain string) (WhoisRecord, error) {
+	if inputDomain == "" {
+		return WhoisRecord{}, errNotFound
+	}
+	if registrable, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(inputDomain)); err == nil {
+		inputDomain = registrable
+	}
+	record, err := rdapLookup(inputDomain)
+	if err == nil || err == errNotFound {
+		return record, err
+	}
+	return rawWhoisLookup(inputDomain)
+}
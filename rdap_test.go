@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// starts a local RDAP server also serving the bootstrap registry
+func startRDAPStub(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	mux.HandleFunc("/dns.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"version": "1.0", "services": [[["test", "example"], ["%s/rdap/"]]]}`, server.URL)
+	})
+	mux.HandleFunc("/rdap/domain/example.test", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rdap+json")
+		http.ServeFile(w, r, "testdata/rdap_domain.json")
+	})
+	mux.HandleFunc("/rdap/domain/", http.NotFound)
+	mux.HandleFunc("/ipv4.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"version": "1.0", "services": [[["192.0.2.0/24"], ["%s/rdap/"]]]}`, server.URL)
+	})
+	mux.HandleFunc("/rdap/ip/192.0.2.10", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rdap+json")
+		http.ServeFile(w, r, "testdata/rdap_ip.json")
+	})
+
+	previous, previousIP := rdapBootstrapURL, rdapIPBootstrapURL
+	rdapBootstrapURL = server.URL + "/dns.json"
+	rdapIPBootstrapURL = server.URL + "/ipv4.json"
+	rdapBootstrapOnce, rdapIPBootstrapOnce = sync.Once{}, sync.Once{}
+	t.Cleanup(func() {
+		rdapBootstrapURL, rdapIPBootstrapURL = previous, previousIP
+		rdapBootstrapOnce, rdapIPBootstrapOnce = sync.Once{}, sync.Once{}
+	})
+}
+
+func TestRDAPLookup(t *testing.T) {
+	startRDAPStub(t)
+	record, err := whoisLookup("www.example.test")
+	if err != nil {
+		t.Fatal("rdap lookup failed:", err)
+	}
+	for _, test := range []struct{ field, expected, got string }{
+		{"source", "rdap", record.Source},
+		{"created", "2021-03-01T10:00:00Z", record.Created},
+		{"updated", "2021-06-15T08:30:00Z", record.Updated},
+		{"expiry", "2022-03-01T10:00:00Z", record.Expiry},
+		{"registrar", "Example Registrar, LLC", record.Registrar},
+		{"registrant org", "Squatter Holdings Ltd", record.RegistrantOrg},
+		{"abuse email", "abuse@registrar.example", record.AbuseEmail},
+	} {
+		if test.got != test.expected {
+			t.Errorf("expected %s '%s', got '%s'", test.field, test.expected, test.got)
+		}
+	}
+	if len(record.Status) != 2 || record.Status[1] != "client transfer prohibited" {
+		t.Errorf("unexpected status codes %v", record.Status)
+	}
+	if len(record.Nameservers) != 2 || record.Nameservers[0] != "ns1.parking.example" {
+		t.Errorf("unexpected name servers %v", record.Nameservers)
+	}
+}
+
+func TestRDAPNotFound(t *testing.T) {
+	startRDAPStub(t)
+	if _, err := whoisLookup("free.test"); err != errNotFound {
+		t.Errorf("expected errNotFound, got %v", err)
+	}
+}
+
+func TestRDAPNoService(t *testing.T) {
+	startRDAPStub(t)
+	if _, err := rdapLookup("example.invalid"); err != errNoRDAP {
+		t.Errorf("expected errNoRDAP, got %v", err)
+	}
+}
+
+func TestIPAbuseLookup(t *testing.T) {
+	startRDAPStub(t)
+	owner, email, err := ipAbuseLookup("192.0.2.10")
+	if err != nil {
+		t.Fatal("rdap ip lookup failed:", err)
+	}
+	if owner != "Example Hosting GmbH" {
+		t.Errorf("expected owner 'Example Hosting GmbH', got '%s'", owner)
+	}
+	if email != "abuse@hosting.example" {
+		t.Errorf("expected email 'abuse@hosting.example', got '%s'", email)
+	}
+	if _, _, err := ipAbuseLookup("198.51.100.1"); err != errNoRDAP {
+		t.Errorf("expected errNoRDAP, got %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	reportSet         = flag.NewFlagSet("report", flag.ContinueOnError)
+	reportInput       = reportSet.String("i", "", "dnsmorph json or ndjson results filepath (default stdin)")
+	reportTemplate    = reportSet.String("t", "", "takedown notice template filepath")
+	reportOutput      = reportSet.String("o", "", "output directory, one notice file per registrar")
+	reportPrint       = reportSet.Bool("print-template", false, "print the default takedown notice template")
+	reportNoCache     = reportSet.Bool("no-cache", false, "disable whois cache")
+	reportRefresh     = reportSet.Bool("refresh", false, "refresh whois cache entries")
+	reportCacheTTL    = reportSet.Duration("cache-ttl", 24*time.Hour, "whois cache time to live")
+	reportDescription = "dnsmorph report [-i results.json] [-t template] [-o directory] [-no-cache] [-refresh] [-cache-ttl duration] [-print-template]"
+	unsafeFilename    = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// default takedown notice, rendered once per registrar
+const defaultReportTemplate = `To: {{join .AbuseContacts ", "}}
+Subject: Abuse report - {{len .Domains}} domain(s) impersonating {{join .Brands ", "}}
+
+Dear {{.Registrar}} abuse team,
+
+we have identified the following domain names registered through your services
+that impersonate {{join .Brands ", "}} and are likely to be used for phishing or fraud.
+{{range .Domains}}
+Domain:             {{.Domain}}
+Impersonates:       {{.Target}} ({{.Technique}} permutation)
+Registered:         {{.WhoisCreation}}
+Expires:            {{.WhoisExpiration}}
+Status:             {{join .WhoisStatus ", "}}
+Name servers:       {{join .NS ", "}}
+IP address:         {{.A}}{{if .HostingOrg}} hosted by {{.HostingOrg}}{{end}}
+{{- if .HostingAbuse}}
+Hosting abuse:      {{.HostingAbuse}}
+{{- end}}
+{{end}}
+We kindly ask you to investigate these registrations and to suspend them in
+accordance with your acceptable use policy.
+
+Report generated on {{.Date}}
+`
+
+// takedownDomain holds the evidence collected for a reported domain
+type takedownDomain struct {
+	Record
+	HostingOrg   string
+	HostingAbuse string
+}
+
+// takedownNotice groups the reported domains of a registrar
+type takedownNotice struct {
+	Registrar     string
+	AbuseContacts []string
+	Brands        []string
+	Domains       []takedownDomain
+	Date          string
+}
+
+// reads dnsmorph json or ndjson results from path, or stdin when path is empty
+func loadResults(path string) ([]Record, error) {
+	var input io.Reader = os.Stdin
+	if path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+	return decodeResults(input)
+}
+
+// decodes json results, either a single document or one record per line
+func decodeResults(input io.Reader) ([]Record, error) {
+	var records []Record
+	decoder := json.NewDecoder(input)
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+		var results struct {
+			Results *[]Record `json:"results"`
+		}
+		if err := json.Unmarshal(value, &results); err != nil {
+			return nil, err
+		}
+		if results.Results != nil {
+			records = append(records, *results.Results...)
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(value, &r); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+}
+
+// appends value to values unless empty or already present
+func appendUnique(values []string, value string) []string {
+	if value == "" {
+		return values
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// groups live and registered domains, or domains with whois data, by registrar, completing
+// missing registrar and hosting abuse contacts with RDAP lookups, owned and probably
+// defensive registrations are never reported
+func buildNotices(records []Record) []takedownNotice {
+	type owner struct{ name, abuse string }
+	owners := make(map[string]owner)
+	notices := make(map[string]*takedownNotice)
+	for _, r := range records {
+		if r.Owned || r.Defensive {
+			continue
+		}
+		if r.Registration != registered && r.A == "" && r.Registrar == "" && r.WhoisCreation == "" {
+			continue
+		}
+		if r.Registrar == "" || r.AbuseContact == "" {
+			if record, _, err := cachedWhoisLookup(r.Domain, !*reportNoCache, *reportRefresh, *reportCacheTTL); err == nil {
+				if r.Registrar == "" {
+					r.Registrar = record.Registrar
+				}
+				if r.AbuseContact == "" {
+					r.AbuseContact = record.AbuseEmail
+				}
+			}
+		}
+		domain := takedownDomain{Record: r}
+		if r.A != "" {
+			o, ok := owners[r.A]
+			if !ok {
+				o.name, o.abuse, _ = ipAbuseLookup(r.A)
+				owners[r.A] = o
+			}
+			domain.HostingOrg, domain.HostingAbuse = o.name, o.abuse
+		}
+		registrar := r.Registrar
+		if registrar == "" {
+			registrar = "unknown registrar"
+		}
+		notice, ok := notices[registrar]
+		if !ok {
+			notice = &takedownNotice{Registrar: registrar, Date: time.Now().Format("2006-01-02")}
+			notices[registrar] = notice
+		}
+		notice.AbuseContacts = appendUnique(notice.AbuseContacts, r.AbuseContact)
+		notice.Brands = appendUnique(notice.Brands, r.Target)
+		notice.Domains = append(notice.Domains, domain)
+	}
+	results := []takedownNotice{}
+	for _, notice := range notices {
+		sort.Slice(notice.Domains, func(i, j int) bool { return notice.Domains[i].Domain < notice.Domains[j].Domain })
+		results = append(results, *notice)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Registrar < results[j].Registrar })
+	return results
+}
+
+// parses the takedown notice template at path, or the default template when path is empty
+func parseReportTemplate(path string) (*template.Template, error) {
+	text := defaultReportTemplate
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	return template.New("notice").Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+}
+
+// returns a unique file name for the notice of a registrar, names without
+// letters or digits falling back to "registrar"
+func noticeFilename(registrar string, used map[string]bool) string {
+	slug := strings.Trim(unsafeFilename.ReplaceAllString(strings.ToLower(registrar), "-"), "-")
+	if slug == "" {
+		slug = "registrar"
+	}
+	name := slug
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s-%d", slug, i)
+	}
+	used[name] = true
+	return name + ".txt"
+}
+
+// renders notices to stdout, or to one file per registrar in outputDir
+func renderNotices(notices []takedownNotice, tmpl *template.Template, outputDir string) error {
+	used := make(map[string]bool)
+	for i, notice := range notices {
+		if outputDir == "" {
+			if i > 0 {
+				fmt.Println(strings.Repeat("-", 72))
+			}
+			if err := tmpl.Execute(os.Stdout, notice); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return err
+		}
+		file, err := os.Create(filepath.Join(outputDir, noticeFilename(notice.Registrar, used)))
+		if err != nil {
+			return err
+		}
+		err = tmpl.Execute(file, notice)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runs the report mode, generating takedown notices from dnsmorph results
+func runReport(args []string) {
+	reportSet.Usage = func() {
+		fmt.Println(reportDescription)
+		reportSet.PrintDefaults()
+		os.Exit(1)
+	}
+	reportSet.Parse(args)
+
+	if *reportPrint {
+		fmt.Print(defaultReportTemplate)
+		return
+	}
+	tmpl, err := parseReportTemplate(*reportTemplate)
+	if err != nil {
+		r.Printf("\nerror reading template: %v\n\n", err)
+		os.Exit(1)
+	}
+	records, err := loadResults(*reportInput)
+	if err != nil {
+		r.Printf("\nerror reading results: %v\n\n", err)
+		os.Exit(1)
+	}
+	if err := renderNotices(buildNotices(records), tmpl, *reportOutput); err != nil {
+		r.Printf("\nerror writing report: %v\n\n", err)
+		os.Exit(1)
+	}
+}
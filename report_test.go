@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTakedownReport(t *testing.T) {
+	startRDAPStub(t)
+	records := []Record{
+		{Target: "example.com", Technique: "addition", Domain: "examplea.com", A: "192.0.2.10", Registration: registered,
+			Registrar: "Example Registrar, LLC", AbuseContact: "abuse@registrar.example", WhoisCreation: "2021-03-01T10:00:00Z"},
+		{Target: "example.com", Technique: "omission", Domain: "exmple.com", Registration: registered,
+			Registrar: "Example Registrar, LLC", AbuseContact: "abuse@registrar.example"},
+		{Target: "example.com", Technique: "homograph", Domain: "exаmple.com", Registration: registered,
+			Registrar: "Other Registrar", AbuseContact: "abuse@other.example"},
+		{Target: "example.com", Technique: "repetition", Domain: "exxample.com", Registration: unregistered},
+		{Target: "example.com", Technique: "hyphenation", Domain: "exam-ple.com", Registrar: "Other Registrar",
+			AbuseContact: "abuse@other.example", WhoisCreation: "2021-04-01T00:00:00Z"},
+		{Target: "example.com", Technique: "subdomain", Domain: "exam.ple.com", A: "192.0.2.10", Registration: registered,
+			Registrar: "Example Registrar, LLC", AbuseContact: "abuse@registrar.example", Owned: true},
+		{Target: "example.com", Technique: "vowel-swap", Domain: "exomple.com", A: "192.0.2.10", Registration: registered,
+			Registrar: "Example Registrar, LLC", AbuseContact: "abuse@registrar.example", Defensive: true},
+	}
+	notices := buildNotices(records)
+	if len(notices) != 2 {
+		t.Fatalf("expected 2 notices, got %d", len(notices))
+	}
+	if len(notices[1].Domains) != 2 || notices[1].Domains[0].Domain != "exam-ple.com" {
+		t.Errorf("expected the whois only record in the second notice, got %v", notices[1].Domains)
+	}
+	if notices[0].Registrar != "Example Registrar, LLC" || len(notices[0].Domains) != 2 {
+		t.Errorf("unexpected first notice %s with %d domains", notices[0].Registrar, len(notices[0].Domains))
+	}
+	if notices[0].Domains[0].HostingAbuse != "abuse@hosting.example" {
+		t.Errorf("expected hosting abuse contact, got '%s'", notices[0].Domains[0].HostingAbuse)
+	}
+
+	tmpl, err := parseReportTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := renderNotices(notices, tmpl, dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "example-registrar-llc.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"To: abuse@registrar.example",
+		"Dear Example Registrar, LLC abuse team",
+		"Domain:             examplea.com",
+		"hosted by Example Hosting GmbH",
+		"Hosting abuse:      abuse@hosting.example",
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected notice to contain '%s'", expected)
+		}
+	}
+}
+
+func TestNoticeFilename(t *testing.T) {
+	used := make(map[string]bool)
+	for _, test := range []struct{ registrar, expected string }{
+		{"Example Registrar, LLC", "example-registrar-llc.txt"},
+		{"Example Registrar LLC", "example-registrar-llc-2.txt"},
+		{"株式会社", "registrar.txt"},
+		{"***", "registrar-2.txt"},
+	} {
+		if name := noticeFilename(test.registrar, used); name != test.expected {
+			t.Errorf("expected %s for %q, got %s", test.expected, test.registrar, name)
+		}
+	}
+}
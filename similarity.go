@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"golang.org/x/net/html"
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// number of consecutive words hashed together as a text feature
+const shingleSize = 3
+
+// minimum number of features of a fingerprint, sparser pages look alike whatever their content
+const minPageFeatures = 16
+
+var (
+	originPages   sync.Map
+	loginInputRe  = regexp.MustCompile(`(?i)user|login|email|account|signin`)
+	loginActionRe = regexp.MustCompile(`(?i)\blog[ -]?(in|on)\b|\bsign[ -]?(in|on)\b`)
+	textSeparator = regexp.MustCompile(`[^\pL\pN]+`)
+)
+
+// pageFeatures holds the fingerprints and form indicators of a landing page
+type pageFeatures struct {
+	htmlHash      uint64
+	textHash      uint64
+	htmlFeatures  int
+	textFeatures  int
+	LoginForm     bool
+	PasswordField bool
+}
+
+// originPage holds the landing page features of a protected domain
+type originPage struct {
+	once     sync.Once
+	features *pageFeatures
+}
+
+// computes a 64 bit simhash over a set of features
+func simhash(features []string) uint64 {
+	var weights [64]int
+	for _, feature := range features {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	var hash uint64
+	for i, weight := range weights {
+		if weight > 0 {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// scores two simhashes from 0 to 100, unrelated documents differ by 32 bits on average
+func simhashSimilarity(a, b uint64) int {
+	distance := bits.OnesCount64(a ^ b)
+	if distance >= 32 {
+		return 0
+	}
+	return 100 - distance*100/32
+}
+
+// extracts structural and text features and login form indicators from an html page, a form
+// being a login form when it holds a password field, or an identifier field and a sign in action
+// or label, so that newsletter and contact forms asking for an email address are left out
+func analysePage(body []byte) *pageFeatures {
+	page := new(pageFeatures)
+	var structure, words []string
+	var skip, forms int
+	formPassword, formIdentifier, formAction := false, false, false
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			keys := []string{}
+			attrs := make(map[string]string)
+			for _, attr := range token.Attr {
+				keys = append(keys, attr.Key)
+				attrs[attr.Key] = strings.ToLower(attr.Val)
+			}
+			sort.Strings(keys)
+			structure = append(structure, token.Data+"["+strings.Join(keys, ",")+"]")
+			if class := attrs["class"]; class != "" {
+				structure = append(structure, token.Data+"."+class)
+			}
+			switch token.Data {
+			case "script", "style", "noscript":
+				if tt == html.StartTagToken {
+					skip++
+				}
+			case "form":
+				forms++
+				if loginActionRe.MatchString(attrs["action"]) {
+					formAction = true
+				}
+			case "input":
+				if attrs["type"] == "password" {
+					page.PasswordField = true
+					if forms > 0 {
+						formPassword = true
+					}
+				} else if forms > 0 && (attrs["type"] == "submit" || attrs["type"] == "button") {
+					if loginActionRe.MatchString(attrs["value"]) {
+						formAction = true
+					}
+				} else if forms > 0 && (attrs["type"] == "email" ||
+					loginInputRe.MatchString(attrs["name"]+" "+attrs["id"]+" "+attrs["autocomplete"])) {
+					formIdentifier = true
+				}
+			}
+		case html.EndTagToken:
+			switch token.Data {
+			case "script", "style", "noscript":
+				if skip > 0 {
+					skip--
+				}
+			case "form":
+				if forms > 0 {
+					forms--
+				}
+				if formPassword || (formIdentifier && formAction) {
+					page.LoginForm = true
+				}
+				formPassword, formIdentifier, formAction = false, false, false
+			}
+		case html.TextToken:
+			if skip == 0 {
+				if forms > 0 && loginActionRe.MatchString(token.Data) {
+					formAction = true
+				}
+				for _, word := range textSeparator.Split(strings.ToLower(token.Data), -1) {
+					if word != "" {
+						words = append(words, word)
+					}
+				}
+			}
+		}
+	}
+	// unterminated forms still count
+	if formPassword || (formIdentifier && formAction) {
+		page.LoginForm = true
+	}
+	var shingles []string
+	for i := 0; i+shingleSize <= len(words); i++ {
+		shingles = append(shingles, strings.Join(words[i:i+shingleSize], " "))
+	}
+	if len(shingles) == 0 && len(words) > 0 {
+		shingles = []string{strings.Join(words, " ")}
+	}
+	page.htmlHash, page.htmlFeatures = simhash(structure), len(structure)
+	page.textHash, page.textFeatures = simhash(shingles), len(shingles)
+	return page
+}
+
+// scores the similarity of two pages from 0 to 100, taking the closest of the html structure
+// and visible text fingerprints since phishing kits often rewrite one and keep the other
+func (p *pageFeatures) similarity(other *pageFeatures) int {
+	if p == nil || other == nil {
+		return 0
+	}
+	score := 0
+	if p.htmlFeatures >= minPageFeatures && other.htmlFeatures >= minPageFeatures {
+		score = simhashSimilarity(p.htmlHash, other.htmlHash)
+	}
+	if p.textFeatures >= minPageFeatures && other.textFeatures >= minPageFeatures {
+		if text := simhashSimilarity(p.textHash, other.textHash); text > score {
+			score = text
+		}
+	}
+	return score
+}
+
+// returns the body of the first successful probe
+func probeBody(probes ...*HTTPProbe) []byte {
+	for _, probe := range probes {
+		if probe != nil && probe.StatusCode >= 200 && probe.StatusCode < 300 && len(probe.body) > 0 {
+			return probe.body
+		}
+	}
+	return nil
+}
+
+// reports whether the first successful probe landed on the protected domain after redirects
+func redirectsToTarget(target string, probes ...*HTTPProbe) bool {
+	for _, probe := range probes {
+		if probe != nil && probe.StatusCode >= 200 && probe.StatusCode < 300 && len(probe.body) > 0 {
+			final, err := url.Parse(probe.FinalURL)
+			return err == nil && final.Hostname() != "" && registrableDomain(final.Hostname()) == registrableDomain(target)
+		}
+	}
+	return false
+}
+
+// fetches and analyses the landing page of the protected domain once per run
+func originPageFeatures(target string) *pageFeatures {
+	value, _ := originPages.LoadOrStore(target, &originPage{})
+	origin := value.(*originPage)
+	origin.once.Do(func() {
+		httpProbe, httpsProbe := httpLookup(target)
+		if body := probeBody(httpsProbe, httpProbe); body != nil {
+			origin.features = analysePage(body)
+		}
+	})
+	return origin.features
+}
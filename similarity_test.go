@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const originalPage = `<html><head><title>Example Bank</title><style>body { color: #333 }</style></head>
+<body><div class="header"><img src="/logo.png" alt="Example Bank"><ul class="menu"><li><a href="/accounts">Accounts</a></li>
+<li><a href="/cards">Cards</a></li><li><a href="/loans">Loans</a></li><li><a href="/help">Help</a></li></ul></div>
+<div class="main"><h1>Welcome to Example Bank online banking</h1>
+<p>Manage your accounts, pay bills and transfer money securely from anywhere in the world.</p>
+<form action="/login" method="post"><input type="text" name="username" placeholder="Customer number">
+<input type="password" name="password"><button type="submit">Sign in</button></form>
+<p>Never share your password or one time codes with anyone, Example Bank will never ask for them by phone or email.</p>
+</div><div class="footer"><p>Copyright Example Bank plc. All rights reserved.</p></div>
+<script>var tracking = "ignored words in script";</script></body></html>`
+
+const parkedPage = `<html><head><title>examp1e.com is for sale</title></head><body>
+<div id="parking"><h2>This domain may be for sale</h2><p>Buy this domain today with our secure escrow service.</p>
+<a href="https://marketplace.example/buy">Make an offer</a><p>Related searches: cheap flights, car insurance, hosting</p></div>
+</body></html>`
+
+func TestAnalysePageLoginForm(t *testing.T) {
+	page := analysePage([]byte(originalPage))
+	if !page.LoginForm || !page.PasswordField {
+		t.Errorf("expected login form and password field, got %v %v", page.LoginForm, page.PasswordField)
+	}
+	page = analysePage([]byte(`<form action="/signin"><input type="email" name="login"><button>Next</button></form>`))
+	if !page.LoginForm || page.PasswordField {
+		t.Errorf("expected two step login form without password, got %v %v", page.LoginForm, page.PasswordField)
+	}
+	page = analysePage([]byte(`<form><label for="id">Sign in with your email</label><input type="email" id="id"></form>`))
+	if !page.LoginForm {
+		t.Error("expected a labelled sign in form to be a login form")
+	}
+	for _, form := range []string{
+		`<form action="/subscribe"><input type="email" name="email"><button>Subscribe</button></form>`,
+		`<form action="/contact"><input type="email" name="email"><textarea name="message"></textarea><input type="submit" value="Send"></form>`,
+	} {
+		if page = analysePage([]byte(form)); page.LoginForm {
+			t.Errorf("expected no login form in %s", form)
+		}
+	}
+	page = analysePage([]byte(parkedPage))
+	if page.LoginForm || page.PasswordField {
+		t.Error("expected no login form on parked page")
+	}
+}
+
+func TestPageSimilarity(t *testing.T) {
+	original := analysePage([]byte(originalPage))
+	if score := original.similarity(analysePage([]byte(originalPage))); score != 100 {
+		t.Errorf("expected identical pages to score 100, got %d", score)
+	}
+	clone := strings.Replace(originalPage, `action="/login"`, `action="https://collector.example/gate.php"`, 1)
+	clone = strings.Replace(clone, "Copyright Example Bank plc.", "Copyright 2021 Example Bank plc.", 1)
+	if score := original.similarity(analysePage([]byte(clone))); score < similarityThreshold {
+		t.Errorf("expected cloned page to score at least %d, got %d", similarityThreshold, score)
+	}
+	if score := original.similarity(analysePage([]byte(parkedPage))); score >= similarityThreshold {
+		t.Errorf("expected parked page to score below %d, got %d", similarityThreshold, score)
+	}
+	if score := original.similarity(nil); score != 0 {
+		t.Errorf("expected missing origin to score 0, got %d", score)
+	}
+}
+
+func TestSparsePageSimilarity(t *testing.T) {
+	original := analysePage([]byte(originalPage))
+	for _, page := range []string{"", "<html><body>Welcome</body></html>", `<html><head><title>Example Bank</title></head><body><div class="main"><p>Loading</p></div></body></html>`} {
+		if score := original.similarity(analysePage([]byte(page))); score != 0 {
+			t.Errorf("expected sparse page %q to score 0, got %d", page, score)
+		}
+	}
+}
+
+func TestRedirectsToTarget(t *testing.T) {
+	landed := func(finalURL string) *HTTPProbe {
+		return &HTTPProbe{StatusCode: 200, FinalURL: finalURL, body: []byte(originalPage)}
+	}
+	for _, test := range []struct {
+		probe    *HTTPProbe
+		expected bool
+	}{
+		{landed("https://www.example.co.uk/en/"), true},
+		{landed("https://example.co.uk"), true},
+		{landed("https://examp1e.co.uk/"), false},
+		{landed("https://example.co.uk.attacker.example/"), false},
+		{&HTTPProbe{StatusCode: 404, FinalURL: "https://www.example.co.uk/"}, false},
+	} {
+		if result := redirectsToTarget("shop.example.co.uk", test.probe); result != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.probe.FinalURL, test.expected, result)
+		}
+	}
+	r := &Record{HTTPS: landed("https://www.example.com/"), Similarity: 0, RedirectsToTarget: true}
+	if label := r.contentLabel(); label != "\tredirects to target" {
+		t.Errorf("expected redirect label, got %q", label)
+	}
+	// the first successful probe decides
+	if redirectsToTarget("example.com", landed("https://examp1e.com/"), landed("https://example.com/")) {
+		t.Error("expected the first successful probe to decide")
+	}
+}
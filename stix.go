@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/net/idna"
+	"io"
+	"time"
+)
+
+// stixNamespace is the STIX 2.1 namespace of deterministic cyber observable identifiers
+var stixNamespace = [16]byte{0x00, 0xab, 0xed, 0xb4, 0xaa, 0x42, 0x46, 0x6c, 0x9c, 0x01, 0xfe, 0xd2, 0x33, 0x15, 0xa9, 0xb7}
+
+// stixOutput writes the result set as a STIX 2.1 bundle
+type stixOutput struct {
+	w       io.Writer
+	now     string
+	seen    map[string]bool
+	objects []interface{}
+}
+
+// stixBundle is a STIX 2.1 bundle
+type stixBundle struct {
+	Type    string        `json:"type"`
+	ID      string        `json:"id"`
+	Objects []interface{} `json:"objects"`
+}
+
+// stixCommon holds the properties shared by STIX objects
+type stixCommon struct {
+	Type        string `json:"type"`
+	SpecVersion string `json:"spec_version"`
+	ID          string `json:"id"`
+	Created     string `json:"created,omitempty"`
+	Modified    string `json:"modified,omitempty"`
+}
+
+// stixIdentity is the protected brand
+type stixIdentity struct {
+	stixCommon
+	Name          string `json:"name"`
+	IdentityClass string `json:"identity_class"`
+}
+
+// stixAddress is an ipv4-addr cyber observable
+type stixAddress struct {
+	stixCommon
+	Value string `json:"value"`
+}
+
+// stixDomain is a domain-name cyber observable carrying the dnsmorph lookup data
+type stixDomain struct {
+	stixCommon
+	Value             string   `json:"value"`
+	Target            string   `json:"x_dnsmorph_target"`
+	Technique         string   `json:"x_dnsmorph_technique"`
+	Status            string   `json:"x_dnsmorph_dns_status,omitempty"`
+	Registration      string   `json:"x_dnsmorph_registration,omitempty"`
+	Nameservers       []string `json:"x_dnsmorph_nameservers,omitempty"`
+	PTR               string   `json:"x_dnsmorph_ptr,omitempty"`
+	InfraMatch        string   `json:"x_dnsmorph_infra_match,omitempty"`
+	CountryCode       string   `json:"x_dnsmorph_country_code,omitempty"`
+	City              string   `json:"x_dnsmorph_city,omitempty"`
+	ASN               uint     `json:"x_dnsmorph_asn,omitempty"`
+	ASOrg             string   `json:"x_dnsmorph_as_org,omitempty"`
+	WhoisCreation     string   `json:"x_dnsmorph_whois_created,omitempty"`
+	WhoisModification string   `json:"x_dnsmorph_whois_updated,omitempty"`
+	WhoisExpiration   string   `json:"x_dnsmorph_whois_expires,omitempty"`
+	Registrar         string   `json:"x_dnsmorph_registrar,omitempty"`
+	RegistrantOrg     string   `json:"x_dnsmorph_registrant_org,omitempty"`
+	WhoisStatus       []string `json:"x_dnsmorph_whois_status,omitempty"`
+	AbuseContact      string   `json:"x_dnsmorph_abuse_contact,omitempty"`
+	Similarity        int      `json:"x_dnsmorph_similarity,omitempty"`
+	LoginForm         bool     `json:"x_dnsmorph_login_form,omitempty"`
+	Parked            bool     `json:"x_dnsmorph_parked,omitempty"`
+	ForSale           bool     `json:"x_dnsmorph_for_sale,omitempty"`
+	CertIssuer        string   `json:"x_dnsmorph_cert_issuer,omitempty"`
+	CertNotBefore     string   `json:"x_dnsmorph_cert_not_before,omitempty"`
+	MX                []string `json:"x_dnsmorph_mx,omitempty"`
+	CatchAll          bool     `json:"x_dnsmorph_catch_all,omitempty"`
+}
+
+// stixIndicator flags a registered candidate
+type stixIndicator struct {
+	stixCommon
+	Name           string   `json:"name"`
+	Description    string   `json:"description"`
+	IndicatorTypes []string `json:"indicator_types"`
+	Pattern        string   `json:"pattern"`
+	PatternType    string   `json:"pattern_type"`
+	ValidFrom      string   `json:"valid_from"`
+	Technique      string   `json:"x_dnsmorph_technique"`
+	Target         string   `json:"x_dnsmorph_target"`
+}
+
+// stixRelationship links two STIX objects
+type stixRelationship struct {
+	stixCommon
+	RelationshipType string `json:"relationship_type"`
+	SourceRef        string `json:"source_ref"`
+	TargetRef        string `json:"target_ref"`
+}
+
+func newSTIXOutput(w io.Writer) outputWriter {
+	return &stixOutput{w: w, now: time.Now().UTC().Format("2006-01-02T15:04:05.000Z"), seen: make(map[string]bool)}
+}
+
+// formats a uuid
+func formatUUID(u []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// returns a name based uuid (RFC 4122 version 5)
+func uuidV5(namespace [16]byte, name string) string {
+	hash := sha1.New()
+	hash.Write(namespace[:])
+	hash.Write([]byte(name))
+	u := hash.Sum(nil)[:16]
+	u[6] = u[6]&0x0f | 0x50
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u)
+}
+
+// returns a random uuid (RFC 4122 version 4)
+func uuidV4() string {
+	u := make([]byte, 16)
+	rand.Read(u)
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u)
+}
+
+// returns the deterministic identifier of an observable from its value
+func stixObservableID(objectType, value string) string {
+	name, _ := json.Marshal(map[string]string{"value": value})
+	return objectType + "--" + uuidV5(stixNamespace, string(name))
+}
+
+// returns a stable identifier of a domain object derived from its defining properties
+func stixObjectID(objectType string, properties ...string) string {
+	name, _ := json.Marshal(properties)
+	return objectType + "--" + uuidV5(stixNamespace, objectType+string(name))
+}
+
+// adds an object to the bundle unless already present
+func (o *stixOutput) add(id string, object interface{}) {
+	if !o.seen[id] {
+		o.seen[id] = true
+		o.objects = append(o.objects, object)
+	}
+}
+
+// adds a relationship between two objects
+func (o *stixOutput) relate(relationshipType, source, target string) {
+	id := stixObjectID("relationship", relationshipType, source, target)
+	o.add(id, stixRelationship{stixCommon{"relationship", "2.1", id, o.now, o.now}, relationshipType, source, target})
+}
+
+func (o *stixOutput) write(r *Record) error {
+	if r.Owned || r.Defensive {
+		return nil
+	}
+	value, err := idna.Lookup.ToASCII(r.Domain)
+	if err != nil {
+		value = r.Domain
+	}
+	brand := stixObjectID("identity", r.Target)
+	o.add(brand, stixIdentity{stixCommon{"identity", "2.1", brand, o.now, o.now}, r.Target, "organization"})
+
+	domain := stixObservableID("domain-name", value)
+	o.add(domain, stixDomain{
+		stixCommon: stixCommon{Type: "domain-name", SpecVersion: "2.1", ID: domain}, Value: value,
+		Target: r.Target, Technique: r.Technique, Status: r.Status, Registration: r.Registration, Nameservers: r.NS,
+		PTR: r.PTR, InfraMatch: r.InfraMatch, CountryCode: r.CountryCode,
+		City: r.City, ASN: r.ASN, ASOrg: r.ASOrg, WhoisCreation: r.WhoisCreation, WhoisModification: r.WhoisModification,
+		WhoisExpiration: r.WhoisExpiration, Registrar: r.Registrar, RegistrantOrg: r.RegistrantOrg, WhoisStatus: r.WhoisStatus,
+		AbuseContact: r.AbuseContact, Similarity: r.Similarity, LoginForm: r.LoginForm, Parked: r.Parked, ForSale: r.ForSale,
+		CertIssuer: r.CertIssuer, CertNotBefore: r.CertNotBefore, MX: r.MX, CatchAll: r.CatchAll,
+	})
+
+	ips := r.IPs
+	if len(ips) == 0 && r.A != "" {
+		ips = []string{r.A}
+	}
+	for _, ip := range ips {
+		address := stixObservableID("ipv4-addr", ip)
+		o.add(address, stixAddress{stixCommon{Type: "ipv4-addr", SpecVersion: "2.1", ID: address}, ip})
+		o.relate("resolves-to", domain, address)
+	}
+
+	if r.Registration == registered {
+		indicator := stixObjectID("indicator", r.Target, value)
+		o.add(indicator, stixIndicator{
+			stixCommon:     stixCommon{"indicator", "2.1", indicator, o.now, o.now},
+			Name:           "Lookalike domain " + value,
+			Description:    fmt.Sprintf("%s is a registered %s permutation of %s", value, r.Technique, r.Target),
+			IndicatorTypes: []string{"malicious-activity"},
+			Pattern:        fmt.Sprintf("[domain-name:value = '%s']", value),
+			PatternType:    "stix",
+			ValidFrom:      o.now,
+			Technique:      r.Technique,
+			Target:         r.Target,
+		})
+		o.relate("related-to", indicator, brand)
+		o.relate("related-to", indicator, domain)
+	}
+	return nil
+}
+
+func (o *stixOutput) close() error {
+	objects := o.objects
+	if objects == nil {
+		objects = []interface{}{}
+	}
+	data, err := json.Marshal(stixBundle{"bundle", "bundle--" + uuidV4(), objects})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(o.w, "%s\n", data)
+	return err
+}
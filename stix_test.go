@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSTIXObservableID(t *testing.T) {
+	// identifiers computed with the STIX namespace by reference implementations
+	for value, id := range map[string]string{
+		"exampel.com": "domain-name--95f11fa8-2c7d-54e1-a0ff-1f7119dc3ec2",
+		"192.0.2.1":   "ipv4-addr--8dded90c-40c0-545a-8027-5b212bb37e8e",
+	} {
+		objectType := strings.SplitN(id, "--", 2)[0]
+		if result := stixObservableID(objectType, value); result != id {
+			t.Errorf("%s: expected %s, got %s", value, id, result)
+		}
+	}
+}
+
+func TestSTIXBundle(t *testing.T) {
+	var buf bytes.Buffer
+	writer := outputFormats["stix"](&buf)
+	for _, r := range []Record{
+		{Target: "example.com", Technique: "transposition", Domain: "exampel.com", A: "192.0.2.1", IPs: []string{"192.0.2.1", "192.0.2.2"},
+			Registration: registered, Registrar: "Example Registrar"},
+		{Target: "example.com", Technique: "addition", Domain: "examplea.com", A: "192.0.2.1", IPs: []string{"192.0.2.1"}, Registration: registered},
+		{Target: "example.com", Technique: "omission", Domain: "exmple.com", Registration: unregistered},
+		{Target: "example.com", Technique: "hyphenation", Domain: "exam-ple.com", A: "192.0.2.3", Registration: registered, Owned: true},
+	} {
+		if err := writer.write(&r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	var bundle struct {
+		Type    string                   `json:"type"`
+		ID      string                   `json:"id"`
+		Objects []map[string]interface{} `json:"objects"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Type != "bundle" || !strings.HasPrefix(bundle.ID, "bundle--") {
+		t.Errorf("unexpected bundle %s %s", bundle.Type, bundle.ID)
+	}
+	counts := make(map[string]int)
+	relationships := make(map[string]int)
+	for _, object := range bundle.Objects {
+		counts[object["type"].(string)]++
+		if object["type"] == "relationship" {
+			relationships[object["relationship_type"].(string)]++
+		}
+		if object["id"] == "domain-name--95f11fa8-2c7d-54e1-a0ff-1f7119dc3ec2" && object["x_dnsmorph_registrar"] != "Example Registrar" {
+			t.Errorf("expected whois data in custom properties, got %v", object)
+		}
+		if object["type"] == "indicator" && object["pattern"] == "[domain-name:value = 'exmple.com']" {
+			t.Error("unexpected indicator of an unregistered domain")
+		}
+	}
+	for objectType, count := range map[string]int{"identity": 1, "domain-name": 3, "ipv4-addr": 2, "indicator": 2, "relationship": 7} {
+		if counts[objectType] != count {
+			t.Errorf("expected %d %s objects, got %d", count, objectType, counts[objectType])
+		}
+	}
+	if relationships["resolves-to"] != 3 {
+		t.Errorf("expected 3 resolves-to relationships, got %d", relationships["resolves-to"])
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang.org/x/net/idna"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// layouts of the dates found in lookup results, tried in order by the date template function
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02", "02-Jan-2006"}
+
+// default templates of the results table, rendered once per record
+const (
+	defaultRecordTemplate = "{{.Domain}}\t{{.A}}\t{{registrationLabel .}}\t{{.WhoisCreation}}\t{{.WhoisModification}}\t" +
+		"{{.Geolocation}}{{contentLabel .}}{{certLabel .}}{{mailLabel .}}\n"
+	verboseRecordTemplate = "{{.Technique}}\t{{.Domain}}\t{{.A}}\t{{registrationLabel .}}\t{{.WhoisCreation}}\t{{.WhoisModification}}\t" +
+		"{{.Geolocation}}{{contentLabel .}}{{certLabel .}}{{mailLabel .}}{{if .WhoisCached}}\t(cached){{end}}\n"
+)
+
+var (
+	recordTemplate  = template.Must(parseTemplate("record", defaultRecordTemplate))
+	verboseTemplate = template.Must(parseTemplate("verbose", verboseRecordTemplate))
+	userTemplate    *template.Template
+)
+
+// templateResults is the data of a results template
+type templateResults struct {
+	Targets   []string
+	Generated time.Time
+	Version   string
+	Results   []Record
+}
+
+// templateOutput renders records through the -template file, the whole result set when it defines a results template
+type templateOutput struct {
+	w       io.Writer
+	tmpl    *template.Template
+	targets []string
+	results []Record
+}
+
+// templateFuncs are the helper functions available to templates
+var templateFuncs = template.FuncMap{
+	"punycode": func(domain string) string {
+		if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+			return ascii
+		}
+		return domain
+	},
+	"join": strings.Join,
+	"date": formatDate,
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"registrationLabel": (*Record).registrationLabel,
+	"contentLabel":      (*Record).contentLabel,
+	"certLabel":         (*Record).certLabel,
+	"mailLabel":         (*Record).mailLabel,
+}
+
+// formats a time, or a date string in a known layout, with layout, unknown dates are returned as is
+func formatDate(layout string, value interface{}) string {
+	switch value := value.(type) {
+	case time.Time:
+		return value.Format(layout)
+	case string:
+		for _, dateLayout := range dateLayouts {
+			if t, err := time.Parse(dateLayout, value); err == nil {
+				return t.Format(layout)
+			}
+		}
+		return value
+	}
+	return fmt.Sprint(value)
+}
+
+// parses a template with the helper functions
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+// loads the template file at path
+func loadTemplate(path string) (*template.Template, error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseTemplate(path, string(text))
+}
+
+func newTemplateOutput(w io.Writer) outputWriter {
+	return &templateOutput{w: w, tmpl: userTemplate}
+}
+
+func (o *templateOutput) write(r *Record) error {
+	if o.tmpl.Lookup("results") != nil {
+		o.targets = appendUnique(o.targets, r.Target)
+		o.results = append(o.results, *r)
+		return nil
+	}
+	return o.tmpl.Execute(o.w, r)
+}
+
+func (o *templateOutput) close() error {
+	if o.tmpl.Lookup("results") == nil {
+		return nil
+	}
+	return o.tmpl.ExecuteTemplate(o.w, "results", templateResults{o.targets, time.Now(), version, o.results})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestDefaultTemplates(t *testing.T) {
+	r := &Record{Technique: "omission", Domain: "exmple.com", A: "192.0.2.1", Registration: registered, WhoisCreation: "2020-01-02",
+		Geolocation: "DE Berlin", WhoisCached: true, MX: []string{"mx.exmple.com"}}
+	for tmpl, expected := range map[*template.Template]string{
+		recordTemplate:  "exmple.com\t192.0.2.1\tregistered\t2020-01-02\t\tDE Berlin\tMX mx.exmple.com\n",
+		verboseTemplate: "omission\texmple.com\t192.0.2.1\tregistered\t2020-01-02\t\tDE Berlin\tMX mx.exmple.com\t(cached)\n",
+	} {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, r); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != expected {
+			t.Errorf("%s: expected %q, got %q", tmpl.Name(), expected, buf.String())
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	for _, test := range []struct {
+		value    interface{}
+		expected string
+	}{
+		{"2020-01-02T03:04:05Z", "02/01/2020"},
+		{"2020-01-02T03:04:05.123+02:00", "02/01/2020"},
+		{"2020-01-02", "02/01/2020"},
+		{"02-Jan-2020", "02/01/2020"},
+		{time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), "02/01/2020"},
+		{"not a date", "not a date"},
+		{"", ""},
+	} {
+		if date := formatDate("02/01/2006", test.value); date != test.expected {
+			t.Errorf("%v: expected %q, got %q", test.value, test.expected, date)
+		}
+	}
+}
+
+// renders records through a user template
+func renderTemplate(t *testing.T, text string, records []Record) string {
+	tmpl, err := parseTemplate("test", text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := userTemplate
+	userTemplate = tmpl
+	defer func() { userTemplate = previous }()
+	var buf bytes.Buffer
+	writer := newTemplateOutput(&buf)
+	for i := range records {
+		if err := writer.write(&records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestTemplateOutput(t *testing.T) {
+	records := []Record{
+		{Target: "example.com", Technique: "homograph", Domain: "еxample.com", NS: []string{"ns1.example.net", "ns2.example.net"}},
+		{Target: "example.com", Technique: "addition", Domain: "examplea.com", Registrar: `Say "hello"`},
+	}
+	// each record is rendered in turn
+	result := renderTemplate(t, `{{punycode .Domain}} {{join .NS ","}} {{json .Registrar}}`+"\n", records)
+	expected := "xn--xample-2of.com ns1.example.net,ns2.example.net \"\"\nexamplea.com  \"Say \\\"hello\\\"\"\n"
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+	// a results template renders the result set once
+	result = renderTemplate(t, `{{define "results"}}{{join .Targets ","}}:{{range .Results}} {{.Domain}}{{end}}{{end}}`, records)
+	if result != "example.com: еxample.com examplea.com" {
+		t.Errorf("unexpected result set rendering %q", result)
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	if _, err := loadTemplate("testdata/missing.tmpl"); err == nil {
+		t.Error("expected an error for a missing template")
+	}
+	if _, err := parseTemplate("bad", "{{.Domain"); err == nil || !strings.Contains(err.Error(), "bad") {
+		t.Errorf("expected a parse error naming the template, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestXLSXColumn(t *testing.T) {
+	for i, expected := range map[int]string{0: "A", 7: "H", 25: "Z", 26: "AA", 62: "BK", 701: "ZZ", 702: "AAA"} {
+		if column := xlsxColumn(i); column != expected {
+			t.Errorf("expected column %s for %d, got %s", expected, i, column)
+		}
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	used := make(map[string]bool)
+	for _, test := range []struct{ target, expected string }{
+		{"example.com", "example.com"},
+		{"Example.com", "Example.com (2)"},
+		{"a[b]:c", "a_b__c"},
+		{strings.Repeat("a", 40) + ".com", strings.Repeat("a", 31)},
+		{strings.Repeat("a", 40) + ".net", strings.Repeat("a", 27) + " (2)"},
+	} {
+		if name := xlsxSheetName(test.target, used); name != test.expected {
+			t.Errorf("expected sheet name %q for %s, got %q", test.expected, test.target, name)
+		}
+	}
+}
+
+func TestXLSXWorkbook(t *testing.T) {
+	var buf bytes.Buffer
+	writer := newXLSXOutput(&buf)
+	for _, r := range []Record{
+		{Target: "example.com", Technique: "omission", Domain: "exmple.com", Registration: registered, Registrar: "R&D <Registrar>"},
+		{Target: "example.org", Technique: "addition", Domain: "examplea.org", Registration: unregistered},
+		{Target: "example.com", Technique: "addition", Domain: "examplea.com", Registration: unregistered},
+	} {
+		writer.write(&r)
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string]string)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(reader)
+		reader.Close()
+		// every part is well formed xml
+		decoder := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			if _, err := decoder.Token(); err != nil {
+				if err != io.EOF {
+					t.Errorf("%s: %v", file.Name, err)
+				}
+				break
+			}
+		}
+		parts[file.Name] = string(data)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `<sheet name="example.com" sheetId="1" r:id="rId1"/><sheet name="example.org" sheetId="2" r:id="rId2"/>`) {
+		t.Errorf("expected one sheet per target, got %s", parts["xl/workbook.xml"])
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, expected := range []string{`state="frozen"`, `<autoFilter ref="A1:` + xlsxColumn(len(csvColumns)-1) + `3"/>`, `<formula>$H2="registered"</formula>`,
+		`<c r="B3" t="inlineStr"><is><t xml:space="preserve">examplea.com</t></is></c>`, "R&amp;D &lt;Registrar&gt;"} {
+		if !strings.Contains(sheet, expected) {
+			t.Errorf("expected %q in the example.com sheet", expected)
+		}
+	}
+}
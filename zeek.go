@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"golang.org/x/net/idna"
+	"io"
+	"strings"
+)
+
+// zeekOutput writes registered candidates and their addresses as a Zeek intel framework file
+type zeekOutput struct {
+	w     io.Writer
+	seen  map[string]bool
+	wrote bool
+}
+
+func newZeekOutput(w io.Writer) outputWriter {
+	return &zeekOutput{w: w, seen: make(map[string]bool)}
+}
+
+// returns the description of a candidate built from its technique and lookup results
+func zeekDescription(r *Record) string {
+	desc := []string{fmt.Sprintf("%s lookalike of %s", r.Technique, r.Target)}
+	if r.Registrar != "" {
+		desc = append(desc, "registrar "+r.Registrar)
+	}
+	if r.WhoisCreation != "" {
+		desc = append(desc, "created "+r.WhoisCreation)
+	}
+	if r.ASOrg != "" {
+		desc = append(desc, fmt.Sprintf("hosted by AS%d %s", r.ASN, r.ASOrg))
+	}
+	if r.Similarity > 0 {
+		desc = append(desc, fmt.Sprintf("similarity score %d", r.Similarity))
+	}
+	if r.LoginForm {
+		desc = append(desc, "login form")
+	}
+	if r.ForSale {
+		desc = append(desc, "for sale")
+	} else if r.Parked {
+		desc = append(desc, "parked")
+	}
+	if r.CertFreeDV && r.CertRecent {
+		desc = append(desc, "new free DV certificate")
+	}
+	if r.CatchAll {
+		desc = append(desc, "catch-all mail server")
+	}
+	return strings.Join(desc, ", ")
+}
+
+// writes an intel item once, tabs and newlines being field and line separators
+func (o *zeekOutput) item(indicator, indicatorType, desc string) error {
+	if o.seen[indicator] {
+		return nil
+	}
+	o.seen[indicator] = true
+	if !o.wrote {
+		if err := o.writeHeader(); err != nil {
+			return err
+		}
+	}
+	desc = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return ' '
+		}
+		return r
+	}, desc)
+	_, err := fmt.Fprintf(o.w, "%s\t%s\tdnsmorph\t%s\n", indicator, indicatorType, desc)
+	return err
+}
+
+// writes the fields header once
+func (o *zeekOutput) writeHeader() error {
+	o.wrote = true
+	_, err := io.WriteString(o.w, "#fields\tindicator\tindicator_type\tmeta.source\tmeta.desc\n")
+	return err
+}
+
+func (o *zeekOutput) write(r *Record) error {
+	if r.Registration != registered || r.Owned || r.Defensive {
+		return nil
+	}
+	// zeek matches the punycode form seen on the wire
+	domain, err := idna.Lookup.ToASCII(r.Domain)
+	if err != nil {
+		return nil
+	}
+	domain = strings.ToLower(domain)
+	desc := zeekDescription(r)
+	if err := o.item(domain, "Intel::DOMAIN", desc); err != nil {
+		return err
+	}
+	ips := r.IPs
+	if len(ips) == 0 && r.A != "" {
+		ips = []string{r.A}
+	}
+	for _, ip := range ips {
+		if err := o.item(ip, "Intel::ADDR", "address of "+domain+", "+desc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// an empty intel file still needs its fields header
+func (o *zeekOutput) close() error {
+	if !o.wrote {
+		return o.writeHeader()
+	}
+	return nil
+}
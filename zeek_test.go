@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestZeekIntel(t *testing.T) {
+	records := []Record{
+		{Target: "example.com", Technique: "transposition", Domain: "exampel.com", Registration: registered,
+			IPs: []string{"192.0.2.1", "192.0.2.2"}, Registrar: "Example\tRegistrar", Similarity: 92, LoginForm: true},
+		{Target: "example.com", Technique: "homoglyph", Domain: "еxample.com", Registration: registered, A: "192.0.2.1"},
+		{Target: "example.com", Technique: "addition", Domain: "examplea.com", Registration: unregistered},
+		{Target: "example.com", Technique: "omission", Domain: "exmple.com", Registration: registered, Owned: true},
+	}
+	var buf bytes.Buffer
+	writer := newZeekOutput(&buf)
+	for i := range records {
+		if err := writer.write(&records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "#fields\tindicator\tindicator_type\tmeta.source\tmeta.desc\n" +
+		"exampel.com\tIntel::DOMAIN\tdnsmorph\ttransposition lookalike of example.com, registrar Example Registrar, similarity score 92, login form\n" +
+		"192.0.2.1\tIntel::ADDR\tdnsmorph\taddress of exampel.com, transposition lookalike of example.com, registrar Example Registrar, similarity score 92, login form\n" +
+		"192.0.2.2\tIntel::ADDR\tdnsmorph\taddress of exampel.com, transposition lookalike of example.com, registrar Example Registrar, similarity score 92, login form\n" +
+		"xn--xample-2of.com\tIntel::DOMAIN\tdnsmorph\thomoglyph lookalike of example.com\n"
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+
+	// an empty result set still writes the header
+	buf.Reset()
+	writer = newZeekOutput(&buf)
+	writer.close()
+	if !strings.HasPrefix(buf.String(), "#fields\t") {
+		t.Errorf("expected a fields header, got %q", buf.String())
+	}
+}